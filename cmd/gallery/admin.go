@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var (
+	admin     = ServeFlags.Bool("admin", false, "serve an upload page at "+AdminPath+" protected with -admin-user and the GALLERY_ADMIN_PASSWORD environment variable, use HTTPS in front of it when not serving on localhost")
+	adminuser = ServeFlags.String("admin-user", "admin", "user name for the admin page")
+)
+
+// AdminPath is where the admin page is served.
+const AdminPath = "/_admin/"
+
+// AdminPasswordEnv is the environment variable containing the admin password.
+const AdminPasswordEnv = "GALLERY_ADMIN_PASSWORD"
+
+// adminMaxUpload limits the size of a single upload request.
+const adminMaxUpload = 1 << 30
+
+// adminHeader must be set on modifying requests, browsers don't allow other
+// sites to set it, which prevents them from using the saved credentials.
+const adminHeader = "X-Gallery-Admin"
+
+// Admin serves the page for uploading photos into the galleries,
+// the site is rebuilt after each upload.
+type Admin struct {
+	User     string
+	Password string
+	// Rebuild is called after files are uploaded.
+	Rebuild func() error
+
+	mu sync.Mutex
+}
+
+// NewAdmin creates the admin handler, it fails when the password isn't set.
+// With watch the watcher rebuilds the site after uploads.
+func NewAdmin(watch bool) (*Admin, error) {
+	password := os.Getenv(AdminPasswordEnv)
+	if password == "" {
+		return nil, fmt.Errorf("%v must be set for -admin", AdminPasswordEnv)
+	}
+	admin := &Admin{User: *adminuser, Password: password}
+	if !watch {
+		admin.Rebuild = func() error {
+			err := Build(false)
+			reloader.Notify()
+			return err
+		}
+	}
+	return admin, nil
+}
+
+// authorized checks the basic authentication of the request.
+func (admin *Admin) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// comparing hashes keeps the time independent of the lengths
+	userSum, wantUser := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(admin.User))
+	passwordSum, wantPassword := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(admin.Password))
+	return subtle.ConstantTimeCompare(userSum[:], wantUser[:])&subtle.ConstantTimeCompare(passwordSum[:], wantPassword[:]) == 1
+}
+
+// ServeHTTP serves the admin page and handles the uploads.
+func (admin *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !admin.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="gallery admin", charset="UTF-8"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == AdminPath:
+		admin.index(w, r)
+	case r.Method == "POST" && r.URL.Path == AdminPath+"upload":
+		if r.Header.Get(adminHeader) == "" {
+			http.Error(w, "missing "+adminHeader+" header", http.StatusForbidden)
+			return
+		}
+		admin.upload(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// AdminGallery is a source directory listed on the admin page.
+type AdminGallery struct {
+	// Dir is slash separated and relative to the source directory.
+	Dir    string
+	Images int
+}
+
+// AdminGalleries returns the directories in the source directory
+// with the number of images in them.
+func AdminGalleries(source string) ([]AdminGallery, error) {
+	var galleries []AdminGallery
+	index := map[string]int{}
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != source && gallery.IsIgnored(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if path != source {
+				rel, err := filepath.Rel(source, path)
+				if err != nil {
+					return err
+				}
+				index[path] = len(galleries)
+				galleries = append(galleries, AdminGallery{Dir: filepath.ToSlash(rel)})
+			}
+			return nil
+		}
+		if i, ok := index[filepath.Dir(path)]; ok && gallery.IsSource(path) {
+			galleries[i].Images++
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	sort.Slice(galleries, func(i, k int) bool {
+		return strings.ToLower(galleries[i].Dir) < strings.ToLower(galleries[k].Dir)
+	})
+	return galleries, err
+}
+
+// index renders the list of galleries with the upload areas.
+func (admin *Admin) index(w http.ResponseWriter, r *http.Request) {
+	galleries, err := AdminGalleries(*sourcedir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = adminTemplate.Execute(w, map[string]interface{}{
+		"Galleries": galleries,
+		"Upload":    AdminPath + "upload",
+		"Header":    adminHeader,
+	})
+	if err != nil {
+		slog.Error("admin page failed", "err", err)
+	}
+}
+
+// validUploadDir returns whether dir is a directory in the source
+// that is scanned, none of its elements may be ignored.
+func validUploadDir(dir string) bool {
+	if !filepath.IsLocal(dir) {
+		return false
+	}
+	for _, name := range strings.Split(dir, string(filepath.Separator)) {
+		if gallery.IsIgnored(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// upload saves the uploaded photos into the gallery directory and
+// rebuilds the site. Existing files aren't replaced.
+func (admin *Admin) upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, adminMaxUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	dir := filepath.FromSlash(strings.Trim(r.FormValue("gallery"), "/"))
+	if !validUploadDir(dir) {
+		http.Error(w, "invalid gallery", http.StatusBadRequest)
+		return
+	}
+	target := filepath.Join(*sourcedir, dir)
+
+	// uploads and rebuilds are done one at a time
+	admin.mu.Lock()
+	defer admin.mu.Unlock()
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var messages []string
+	saved := 0
+	for _, header := range r.MultipartForm.File["files"] {
+		name := filepath.Base(filepath.FromSlash(strings.ReplaceAll(header.Filename, `\`, "/")))
+		if !gallery.IsSource(name) || gallery.IsIgnored(name) {
+			messages = append(messages, name+": not a supported image or video")
+			continue
+		}
+		path := filepath.Join(target, name)
+		if FileExists(path) {
+			messages = append(messages, name+": already exists")
+			continue
+		}
+
+		err := imgproc.WriteAtomic(path, func(tmp string) error {
+			src, err := header.Open()
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			dst, err := os.Create(tmp)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(dst, src); err != nil {
+				dst.Close()
+				return err
+			}
+			return dst.Close()
+		})
+		if err != nil {
+			messages = append(messages, name+": "+err.Error())
+			continue
+		}
+		saved++
+		slog.Info("uploaded", "file", path)
+	}
+
+	if saved > 0 && admin.Rebuild != nil {
+		if err := admin.Rebuild(); err != nil {
+			messages = append(messages, "build failed: "+err.Error())
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(messages) > 0 && saved == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	fmt.Fprintf(w, "%d files uploaded into %v\n", saved, filepath.ToSlash(dir))
+	for _, message := range messages {
+		fmt.Fprintln(w, message)
+	}
+}
+
+// adminTemplate is the upload page, it doesn't depend on the theme.
+var adminTemplate = template.Must(template.New("admin").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Gallery admin</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.gallery { border: 2px dashed #bbb; border-radius: 6px; padding: 1rem; margin: 0.75rem 0; }
+.gallery.over { border-color: #27c; background: #eef5fc; }
+.gallery h2 { font-size: 1.1rem; margin: 0; }
+.count { color: #777; font-weight: normal; }
+.status { white-space: pre-wrap; color: #555; font-size: 0.9rem; margin: 0.5rem 0 0; }
+</style>
+</head>
+<body>
+<h1>Galleries</h1>
+<p>Drop photos on a gallery or choose them with the file picker, the site is rebuilt after each upload.</p>
+
+<form class="gallery new">
+	<h2><label>New gallery <input name="gallery" placeholder="2024/summer" required></label></h2>
+	<input type="file" name="files" multiple accept="image/*,video/*">
+	<p class="status"></p>
+</form>
+
+{{range .Galleries}}
+<form class="gallery" data-gallery="{{.Dir}}">
+	<h2>{{.Dir}} <span class="count">{{.Images}} images</span></h2>
+	<input type="file" name="files" multiple accept="image/*,video/*">
+	<p class="status"></p>
+</form>
+{{end}}
+
+<script>
+document.querySelectorAll(".gallery").forEach(function(form){
+	var status = form.querySelector(".status");
+	function upload(files){
+		var name = form.dataset.gallery || form.elements.gallery.value.trim();
+		if(!name){ status.textContent = "Enter the gallery name first."; return; }
+		var data = new FormData();
+		data.append("gallery", name);
+		for(var i = 0; i < files.length; i++){ data.append("files", files[i]); }
+		status.textContent = "Uploading and rebuilding " + files.length + " files...";
+		fetch({{.Upload}}, {method: "POST", body: data, headers: {[{{.Header}}]: "1"}})
+			.then(function(resp){ return resp.text().then(function(text){
+				status.textContent = text;
+				if(resp.ok && !form.dataset.gallery){ location.reload(); }
+			}); })
+			.catch(function(err){ status.textContent = "Upload failed: " + err; });
+	}
+	form.addEventListener("submit", function(e){ e.preventDefault(); });
+	form.elements.files.addEventListener("change", function(){ upload(this.files); this.value = ""; });
+	form.addEventListener("dragover", function(e){ e.preventDefault(); form.classList.add("over"); });
+	form.addEventListener("dragleave", function(){ form.classList.remove("over"); });
+	form.addEventListener("drop", function(e){
+		e.preventDefault();
+		form.classList.remove("over");
+		upload(e.dataTransfer.files);
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidUploadDir(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"summer", true},
+		{"2020/summer", true},
+		{"", false},
+		{"../summer", false},
+		{"/summer", false},
+		{".hidden", false},
+		{"a/.hidden/b", false},
+		{"a/@eaDir", false},
+		{"#recycle/summer", false},
+	}
+	for _, test := range tests {
+		if got := validUploadDir(filepath.FromSlash(test.dir)); got != test.want {
+			t.Errorf("validUploadDir(%q) = %v, expected %v", test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"html/template"
+)
+
+var analytics = BuildFlags.String("analytics", "", "HTML snippet added to the head of every page, e.g. the Plausible, GoatCounter or Google Analytics script tag")
+
+// AnalyticsSnippet returns the analytics snippet, it's inserted as is.
+func AnalyticsSnippet() template.HTML { return template.HTML(*analytics) }
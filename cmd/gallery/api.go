@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var (
+	jsonapi  = BuildFlags.Bool("json", false, "write index.json and a JSON document for each gallery describing the images for apps")
+	headless = BuildFlags.Bool("headless", false, "write only the processed images and the JSON documents, without HTML pages, feeds or static files")
+)
+
+// JSONEnabled returns whether the JSON documents are written.
+func JSONEnabled() bool { return *jsonapi || *headless }
+
+// APIGallery describes a gallery in the JSON documents.
+type APIGallery struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Date        string        `json:"date,omitempty"`
+	URL         string        `json:"url"`
+	JSON        string        `json:"json"`
+	Cover       string        `json:"cover,omitempty"`
+	Count       int           `json:"count"`
+	Galleries   []*APIGallery `json:"galleries,omitempty"`
+	Images      []*APIImage   `json:"images,omitempty"`
+}
+
+// APIImage describes an image and its published files.
+type APIImage struct {
+	Name       string          `json:"name"`
+	Title      string          `json:"title"`
+	Caption    string          `json:"caption,omitempty"`
+	Kind       string          `json:"kind"`
+	URL        string          `json:"url"`
+	File       string          `json:"file"`
+	Thumb      string          `json:"thumb"`
+	WebP       string          `json:"webp,omitempty"`
+	Poster     string          `json:"poster,omitempty"`
+	Original   string          `json:"original,omitempty"`
+	Width      int             `json:"width,omitempty"`
+	Height     int             `json:"height,omitempty"`
+	Date       string          `json:"date"`
+	Tags       []string        `json:"tags,omitempty"`
+	Renditions []*APIRendition `json:"renditions,omitempty"`
+	Exif       *APIMetadata    `json:"exif,omitempty"`
+}
+
+// APIRendition is a downscaled version of a photo.
+type APIRendition struct {
+	File   string `json:"file"`
+	WebP   string `json:"webp,omitempty"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// APIMetadata is the camera information of an image,
+// the location is left out with -gps-privacy.
+type APIMetadata struct {
+	Camera      string  `json:"camera,omitempty"`
+	Lens        string  `json:"lens,omitempty"`
+	FocalLength string  `json:"focalLength,omitempty"`
+	Aperture    string  `json:"aperture,omitempty"`
+	Shutter     string  `json:"shutter,omitempty"`
+	ISO         int     `json:"iso,omitempty"`
+	Taken       string  `json:"taken,omitempty"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+}
+
+// JSONLink returns the link to the JSON document of the gallery.
+func JSONLink(g *gallery.Gallery) string {
+	return path.Join(g.PageLink(), "index.json")
+}
+
+// NewAPIGallery describes the gallery without its images.
+func NewAPIGallery(g *gallery.Gallery) *APIGallery {
+	api := &APIGallery{
+		Title:       g.Title,
+		Description: PlainText(string(g.Description)),
+		URL:         g.PageLink() + "/",
+		JSON:        JSONLink(g),
+		Count:       len(g.Images),
+	}
+	if !g.Date.IsZero() {
+		api.Date = g.Date.Format("2006-01-02")
+	}
+	cover := g.Cover
+	if cover == nil {
+		cover = g.ChildCover()
+	}
+	if cover != nil {
+		api.Cover = cover.ThumbLink()
+	}
+	return api
+}
+
+// NewAPIImage describes the image and its published files.
+func NewAPIImage(image *gallery.Image) *APIImage {
+	api := &APIImage{
+		Name:     image.Name,
+		Title:    image.Title,
+		Caption:  image.Caption,
+		Kind:     image.Kind,
+		URL:      image.PageLink(),
+		File:     image.ImageLink(),
+		Thumb:    image.ThumbLink(),
+		WebP:     image.WebPLink(),
+		Original: image.OriginalLink(),
+		Date:     image.Date().Format(time.RFC3339),
+		Tags:     image.Tags,
+	}
+	if image.Poster != "" {
+		api.Poster = image.PosterLink()
+	}
+	if large := image.Large(); large != nil {
+		api.Width, api.Height = large.Width, large.Height
+	}
+	for _, rendition := range image.Renditions {
+		api.Renditions = append(api.Renditions, &APIRendition{
+			File:   rendition.Link(),
+			WebP:   rendition.WebPLink(),
+			Width:  rendition.Width,
+			Height: rendition.Height,
+		})
+	}
+	if meta := image.Metadata; !meta.IsZero() {
+		api.Exif = &APIMetadata{
+			Camera:      meta.Camera,
+			Lens:        meta.Lens,
+			FocalLength: meta.FocalLength,
+			Aperture:    meta.Aperture,
+			Shutter:     meta.Shutter,
+			ISO:         meta.ISO,
+		}
+		if !meta.Taken.IsZero() {
+			api.Exif.Taken = meta.Taken.Format(time.RFC3339)
+		}
+		if meta.Location != nil && !*gpsprivacy {
+			api.Exif.Latitude = meta.Location.Latitude
+			api.Exif.Longitude = meta.Location.Longitude
+		}
+	}
+	return api
+}
+
+// CreateGalleryJSON writes the JSON document of the gallery.
+func CreateGalleryJSON(g *gallery.Gallery) {
+	api := NewAPIGallery(g)
+	for _, child := range g.PublishedChildren() {
+		api.Galleries = append(api.Galleries, NewAPIGallery(child))
+	}
+	for _, image := range g.Images {
+		api.Images = append(api.Images, NewAPIImage(image))
+	}
+	writeJSON(filepath.Join(g.Unbound, "index.json"), api)
+}
+
+// CreateIndexJSON writes index.json listing the top-level galleries.
+func CreateIndexJSON(roots []*gallery.Gallery) {
+	index := struct {
+		Title     string        `json:"title"`
+		Galleries []*APIGallery `json:"galleries"`
+	}{Title: "Galleries"}
+	for _, g := range roots {
+		index.Galleries = append(index.Galleries, NewAPIGallery(g))
+	}
+	writeJSON("index.json", index)
+}
+
+// writeJSON writes v as JSON to the output file name.
+func writeJSON(name string, v interface{}) {
+	name = Output(name)
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = WriteOutput(name, data)
+	}
+	if err != nil {
+		failures.Add("json", name, err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/egonelbre/gallery"
+)
+
+var ziparchives = BuildFlags.String("zip", "", "create a ZIP download of each gallery: originals, large or empty to disable; "+
+	"originals keep all their metadata, including GPS, and are only used for the galleries publishing them, see -originals, "+
+	"the other galleries get the large renditions")
+
+// ValidZip returns an error when the ZIP mode is not known.
+func ValidZip(mode string) error {
+	switch mode {
+	case "", "originals", "large":
+		return nil
+	}
+	return fmt.Errorf("unknown zip mode %q", mode)
+}
+
+// zipSources returns the files included in the gallery ZIP and the mode
+// used for them. The originals are only included when the gallery
+// publishes them, otherwise the ZIP contains the large renditions,
+// which don't contain the private metadata.
+func zipSources(g *gallery.Gallery, mode string) ([]string, string) {
+	if mode == "originals" && !g.PublishesOriginals(*originals) {
+		slog.Warn("gallery doesn't publish originals, zipping the large renditions", "gallery", g.Path)
+		mode = "large"
+	}
+
+	var files []string
+	for _, image := range g.Images {
+		if mode == "originals" {
+			files = append(files, image.Raw)
+		} else {
+			files = append(files, Output(image.Path))
+		}
+	}
+	return files, mode
+}
+
+// zipFingerprint identifies the contents of a ZIP by file names, sizes and
+// modification times, it's stored as the archive comment.
+func zipFingerprint(mode string, files []string) string {
+	hash := sha256.New()
+	fmt.Fprintln(hash, mode)
+	for _, file := range files {
+		stat, err := zipStat(mode, file)
+		if err != nil {
+			fmt.Fprintln(hash, file)
+			continue
+		}
+		fmt.Fprintln(hash, file, stat.Size(), stat.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// CreateZip creates the ZIP download of the gallery,
+// an existing ZIP is kept when the contents haven't changed.
+func CreateZip(g *gallery.Gallery, mode string) error {
+	files, mode := zipSources(g, mode)
+	fingerprint := zipFingerprint(mode, files)
+
+	target := Output(g.ZipFile())
+	MarkOutput(target)
+	if OutputExists(target) && zipComment(target) == fingerprint {
+		return nil
+	}
+
+	return WriteOutputFile(target, func(tmp string) error {
+		out, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+
+		archive := zip.NewWriter(out)
+		for _, file := range files {
+			if err := addToZip(archive, mode, file); err != nil {
+				archive.Close()
+				out.Close()
+				return err
+			}
+		}
+		archive.SetComment(fingerprint)
+
+		if err := archive.Close(); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// zipStat returns the information of a file included in the ZIP.
+func zipStat(mode, file string) (os.FileInfo, error) {
+	if mode == "originals" {
+		return gallery.Files.Stat(file)
+	}
+	return OutputStat(file)
+}
+
+// zipComment returns the comment of the published ZIP.
+func zipComment(path string) string {
+	file, done, err := LocalOutput(path)
+	if err != nil {
+		return ""
+	}
+	defer done()
+
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return ""
+	}
+	defer archive.Close()
+	return archive.Comment
+}
+
+func addToZip(archive *zip.Writer, mode, file string) error {
+	if mode == "originals" {
+		if err := gallery.Files.Fetch(file); err != nil {
+			return err
+		}
+	} else {
+		local, done, err := LocalOutput(file)
+		if err != nil {
+			return err
+		}
+		defer done()
+		file = local
+	}
+
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	// images are already compressed
+	header.Method = zip.Store
+
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
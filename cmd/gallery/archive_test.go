@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/egonelbre/gallery"
+)
+
+func TestZipSources(t *testing.T) {
+	publish := func(v bool) *bool { return &v }
+	tests := []struct {
+		name      string
+		flag      string
+		originals *bool
+		mode      string
+		want      string
+	}{
+		{"large", "false", nil, "large", "large"},
+		{"published by flag", "true", nil, "originals", "originals"},
+		{"published by gallery", "false", publish(true), "originals", "originals"},
+		{"not published", "false", nil, "originals", "large"},
+		{"withheld by gallery", "true", publish(false), "originals", "large"},
+	}
+	for _, test := range tests {
+		setFlags(t, map[string]string{"originals": test.flag, "output": "public"})
+		g := &gallery.Gallery{Path: "trip"}
+		g.Config.Originals = test.originals
+		g.Images = []*gallery.Image{{Raw: filepath.Join("trip", "a.jpg"), Path: filepath.Join("images", "trip", "a.jpg")}}
+
+		files, mode := zipSources(g, test.mode)
+		want := []string{filepath.Join("trip", "a.jpg")}
+		if test.want == "large" {
+			want = []string{Output(filepath.Join("images", "trip", "a.jpg"))}
+		}
+		if mode != test.want || !reflect.DeepEqual(files, want) {
+			t.Errorf("%v: zipped %v %v, expected %v %v", test.name, mode, files, test.want, want)
+		}
+	}
+}
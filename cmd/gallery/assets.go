@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/egonelbre/gallery/render"
+)
+
+var minify = BuildFlags.Bool("minify", true, "minify CSS and JavaScript in the static directories")
+
+// assets maps static files, e.g. "css/styles.css", to their fingerprinted links.
+var assets = map[string]string{}
+
+// AssetLink returns the fingerprinted link of a static file,
+// files that are not fingerprinted are linked as is.
+func AssetLink(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if link, ok := assets[name]; ok {
+		return link
+	}
+	return "/" + name
+}
+
+// CopyStatic publishes the static directories into the output,
+// each directory keeps its name. Missing directories are taken
+// from the default theme when it has them.
+func CopyStatic() error {
+	assets = map[string]string{}
+	for _, dir := range StaticDirs() {
+		var files fs.FS = os.DirFS(dir)
+		if !FileExists(dir) && render.HasThemeDir(dir) {
+			sub, err := fs.Sub(render.Theme, filepath.ToSlash(dir))
+			if err != nil {
+				return err
+			}
+			files = sub
+		}
+		if err := PublishAssets(files, filepath.Base(dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishAssets copies files into the output directory prefix.
+// CSS and JavaScript are minified and additionally written
+// with a content hash in the name, e.g. "styles.1a2b3c4d.css".
+func PublishAssets(files fs.FS, prefix string) error {
+	return fs.WalkDir(files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+
+		name = path.Join(filepath.ToSlash(prefix), name)
+		ext := path.Ext(name)
+		if ext != ".css" && ext != ".js" {
+			return WriteOutput(Output(name), data)
+		}
+
+		if *minify {
+			if ext == ".css" {
+				data = render.MinifyCSS(data)
+			} else {
+				data = render.MinifyJS(data)
+			}
+		}
+
+		sum := sha256.Sum256(data)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+		assets[name] = "/" + hashed
+
+		if err := WriteOutput(Output(name), data); err != nil {
+			return err
+		}
+		return WriteOutput(Output(hashed), data)
+	})
+}
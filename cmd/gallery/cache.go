@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var cachedir = BuildFlags.String("cache-dir", "", "directory keeping processed outputs by source content and settings, reused when sources are moved or the output is rebuilt from scratch; empty disables the cache")
+
+// CacheFile returns the path in the cache of an output created from
+// a source with the content hash and settings, it returns "" when
+// the cache is disabled.
+func CacheFile(output, hash, settings string) string {
+	if *cachedir == "" || hash == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(hash + "\n" + settings))
+	key := fmt.Sprintf("%x", sum[:16])
+	return filepath.Join(*cachedir, key[:2], key+strings.ToLower(filepath.Ext(output)))
+}
+
+// StoreCache adds output to the cache, outputs are hard linked when possible.
+func StoreCache(output, hash, settings string) {
+	cached := CacheFile(output, hash, settings)
+	if cached == "" {
+		return
+	}
+	file, done, err := LocalOutput(output)
+	if err == nil {
+		err = imgproc.LinkFile(file, cached)
+		done()
+	}
+	if err != nil {
+		slog.Warn("caching failed", "file", output, "err", err)
+	}
+}
+
+// RestoreCache publishes the cached copy of output, it returns whether one was found.
+func RestoreCache(output, hash, settings string) bool {
+	cached := CacheFile(output, hash, settings)
+	if cached == "" || !FileExists(cached) {
+		return false
+	}
+	err := WriteOutputFile(output, func(file string) error {
+		return imgproc.LinkFile(cached, file)
+	})
+	if err != nil {
+		slog.Warn("restoring from cache failed", "file", output, "err", err)
+		return false
+	}
+	slog.Debug("restored from cache", "file", output)
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	clean  = BuildFlags.Bool("clean", false, "remove files from the output directory that are not part of the build")
+	dryrun = CleanFlags.Bool("dry-run", false, "with -clean list the orphaned files instead of removing them")
+)
+
+// outputs contains the files that were created or kept during the build.
+var outputs = struct {
+	sync.Mutex
+	files map[string]bool
+}{files: map[string]bool{}}
+
+// ResetOutputs forgets the files of the previous build.
+func ResetOutputs() {
+	outputs.Lock()
+	outputs.files = map[string]bool{}
+	outputs.Unlock()
+}
+
+// MarkOutput notes that path is part of the build.
+func MarkOutput(path string) {
+	outputs.Lock()
+	outputs.files[filepath.Clean(path)] = true
+	outputs.Unlock()
+}
+
+// WriteOutput writes data to path and marks it as part of the build.
+func WriteOutput(path string, data []byte) error {
+	MarkOutput(path)
+	return putSite(siteName(path), data)
+}
+
+// Orphans returns the files of the site in dir that are not part of the build.
+func Orphans(dir string) ([]string, error) {
+	files, err := Site().List()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs.Lock()
+	defer outputs.Unlock()
+
+	var orphans []string
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if !outputs.files[path] {
+			orphans = append(orphans, path)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// Clean removes the orphaned files in dir and the directories left empty,
+// with dryRun the orphans are only printed.
+func Clean(dir string, dryRun bool) error {
+	orphans, err := Orphans(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, orphan := range orphans {
+		if dryRun {
+			slog.Info("orphan", "file", orphan)
+			continue
+		}
+		slog.Info("removing", "file", orphan)
+		if err := Site().Delete(siteName(orphan)); err != nil {
+			return err
+		}
+		manifest.Forget(orphan)
+	}
+	if _, ok := Site().(LocalStorage); dryRun || !ok {
+		return nil
+	}
+	return removeEmptyDirs(dir)
+}
+
+// removeEmptyDirs removes the empty directories under dir.
+func removeEmptyDirs(dir string) error {
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != dir {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove the deepest directories first
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, path := range dirs {
+		if entries, err := ioutil.ReadDir(path); err == nil && len(entries) == 0 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// Command is a subcommand of the gallery tool.
+type Command struct {
+	Name  string
+	Usage string
+	Short string
+	// Source is set for the commands that read the galleries,
+	// a remote source is listed before they run.
+	Source bool
+	// Flags are the flag groups the command accepts in addition to CommonFlags.
+	Flags []*flag.FlagSet
+	// Run executes the command with the arguments following the name.
+	Run func(args []string) error
+}
+
+// Commands lists the available subcommands, the first one is the default.
+var Commands = []*Command{
+	{
+		Name:   "build",
+		Flags:  []*flag.FlagSet{SourceFlags, OutputFlags, BuildFlags},
+		Short:  "generate the site into the output directory",
+		Source: true,
+		Run: func(args []string) error {
+			err := Build(*pagesonly)
+			if !*watch {
+				return err
+			}
+			// keep watching, the failures may be fixed by the next change
+			if err != nil {
+				slog.Error(err.Error())
+			}
+			return Watch()
+		},
+	},
+	{
+		Name:   "serve",
+		Flags:  []*flag.FlagSet{SourceFlags, OutputFlags, BuildFlags, ServeFlags},
+		Short:  "generate the site and serve it over HTTP, use -watch for live reload",
+		Source: true,
+		Run: func(args []string) error {
+			if err := LocalSite("serve"); err != nil {
+				return err
+			}
+			// the site is served even when some files failed
+			if err := Build(*pagesonly); err != nil {
+				slog.Error(err.Error())
+			}
+			if *watch {
+				go func() {
+					err := Watch()
+					slog.Error("watching failed", "err", err)
+					os.Exit(ExitFailure)
+				}()
+			}
+			return Serve(*addr, *watch)
+		},
+	},
+	{
+		Name:   "clean",
+		Flags:  []*flag.FlagSet{SourceFlags, OutputFlags, BuildFlags, CleanFlags},
+		Short:  "generate the site and remove orphaned outputs, use -dry-run to list them",
+		Source: true,
+		Run: func(args []string) error {
+			*clean = true
+			return Build(false)
+		},
+	},
+	{
+		Name:   "deploy",
+		Flags:  []*flag.FlagSet{SourceFlags, OutputFlags, DeployFlags},
+		Short:  "generate the site with the configured settings and publish it to -deploy-target",
+		Source: true,
+		Run: func(args []string) error {
+			if err := LocalSite("deploy"); err != nil {
+				return err
+			}
+			deployer, err := NewDeployer(*deploytarget)
+			if err != nil {
+				return err
+			}
+			if err := Build(*pagesonly); err != nil {
+				return err
+			}
+			return Deploy(*outputdir, *deploytarget, deployer)
+		},
+	},
+	{
+		Name:  "init",
+		Usage: "init [directory]",
+		Short: "create a new site with the default theme and configuration",
+		Run:   InitCommand,
+	},
+	{
+		Name:  "new",
+		Flags: []*flag.FlagSet{SourceFlags},
+		Usage: "new <directory> [title]",
+		Short: "create a gallery in the source directory",
+		Run:   NewGalleryCommand,
+	},
+	{
+		Name:   "contact-sheet",
+		Flags:  []*flag.FlagSet{SourceFlags, ContactSheetFlags},
+		Usage:  "contact-sheet <gallery> [pdf]",
+		Short:  "lay out the thumbnails of a gallery on printable PDF pages",
+		Source: true,
+		Run:    ContactSheetCommand,
+	},
+	{
+		Name:  "import",
+		Flags: []*flag.FlagSet{SourceFlags, ImportFlags},
+		Usage: "import <takeout|flickr|lightroom> <export>...",
+		Short: "import the albums of a photo service export as galleries",
+		Run:   ImportCommand,
+	},
+	{
+		Name:   "validate",
+		Flags:  []*flag.FlagSet{SourceFlags},
+		Short:  "check the galleries and settings without generating anything",
+		Source: true,
+		Run:    ValidateCommand,
+	},
+}
+
+// FindCommand returns the command with the name, nil when it doesn't exist.
+func FindCommand(name string) *Command {
+	for _, command := range Commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// FlagSet returns the flags the command accepts.
+func (command *Command) FlagSet() *flag.FlagSet {
+	flags := MergeFlags(command.Name, append([]*flag.FlagSet{CommonFlags}, command.Flags...)...)
+	flags.Usage = func() { command.PrintUsage(flags) }
+	return flags
+}
+
+// PrintUsage prints the usage and the flags of the command.
+func (command *Command) PrintUsage(flags *flag.FlagSet) {
+	out := os.Stderr
+	usage := command.Usage
+	if usage == "" {
+		usage = command.Name
+	}
+	fmt.Fprintf(out, "Usage: %s %s [flags]\n\n%s.\n\nFlags:\n", filepath.Base(os.Args[0]), usage, command.Short)
+	flags.SetOutput(out)
+	flags.PrintDefaults()
+}
+
+// Usage prints the commands and the common flags.
+func Usage() {
+	out := os.Stderr
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, command := range Commands {
+		usage := command.Usage
+		if usage == "" {
+			usage = command.Name
+		}
+		fmt.Fprintf(out, "  %-32s %s\n", usage, command.Short)
+	}
+	fmt.Fprintf(out, "\nCommon flags:\n")
+	CommonFlags.SetOutput(out)
+	CommonFlags.PrintDefaults()
+	fmt.Fprintf(out, "\nUse \"%s <command> -help\" for the flags of a command.\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(out, "The exit status is %d when some files failed and %d for invalid usage or configuration.\n", ExitFailure, ExitUsage)
+}
+
+// NewGalleryCommand creates a gallery directory with a gallery.yaml.
+func NewGalleryCommand(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: new <directory> [title]")
+	}
+
+	dir := filepath.Join(*sourcedir, filepath.FromSlash(args[0]))
+	if FileExists(dir) {
+		return fmt.Errorf("%v already exists", dir)
+	}
+
+	title := filepath.Base(dir)
+	if len(args) == 2 {
+		title = args[1]
+	}
+
+	config := fmt.Sprintf("title: %q\ndate: %v\nvisibility: %v\n",
+		title, time.Now().Format("2006-01-02"), gallery.VisibilityPublic)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, gallery.ConfigName)
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Created ", path)
+	return nil
+}
+
+// ValidateCommand loads the galleries and reports the problems.
+func ValidateCommand(args []string) error {
+	galleries, _, err := gallery.Load(*sourcedir, *organize)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, g := range galleries {
+		for _, problem := range gallery.Prepare(g, *sortorder) {
+			fmt.Println(problem)
+			count++
+		}
+		for _, image := range g.Images {
+			if err := CheckSource(image); err != nil {
+				fmt.Printf("%v: %v\n", image.Raw, err)
+				count++
+			}
+		}
+	}
+
+	if count > 0 {
+		return fmt.Errorf("found %d problems", count)
+	}
+	fmt.Printf("%d galleries are valid\n", len(galleries))
+	return nil
+}
+
+// CheckSource checks that the header of a photo can be read,
+// sources decoded by external tools are not checked.
+func CheckSource(image *gallery.Image) error {
+	if image.Kind != gallery.KindPhoto {
+		return nil
+	}
+	if imgproc.IsExternal(image.Raw) {
+		return nil
+	}
+	file, err := gallery.Files.Open(image.Raw)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if width, height := imgproc.SizeOf(file); width == 0 || height == 0 {
+		return fmt.Errorf("unable to read image")
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+var contactcolumns = ContactSheetFlags.Int("contact-columns", 4, "number of thumbnails in a row of a contact sheet")
+
+// Contact sheet layout in points.
+const (
+	contactMargin  = 36.0
+	contactGap     = 12.0
+	contactHeader  = 28.0
+	contactCaption = 22.0
+	contactDPI     = 200.0
+)
+
+// ContactSheetCommand writes the contact sheet of a gallery into a PDF.
+func ContactSheetCommand(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: contact-sheet <gallery> [pdf]")
+	}
+
+	galleries, _, err := gallery.Load(*sourcedir, *organize)
+	if err != nil {
+		return err
+	}
+	g := FindGallery(galleries, args[0])
+	if g == nil {
+		return fmt.Errorf("gallery %q not found", args[0])
+	}
+	if len(g.Images) == 0 {
+		return fmt.Errorf("gallery %q has no images", args[0])
+	}
+	for _, problem := range gallery.Prepare(g, *sortorder) {
+		slog.Warn(problem.Error(), "gallery", g.Name)
+	}
+
+	path := filepath.Base(g.Unbound) + "-contact.pdf"
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	pdf := ContactSheet(g)
+	if err := imgproc.WriteFile(path, pdf.Bytes()); err != nil {
+		return err
+	}
+	slog.Info("contact sheet written", "file", path, "images", len(g.Images), "pages", pdf.PageCount())
+	return nil
+}
+
+// FindGallery returns the gallery with the published or source path name,
+// ignoring case, nil when there isn't one.
+func FindGallery(galleries map[string]*gallery.Gallery, name string) *gallery.Gallery {
+	name = strings.ToLower(strings.Trim(filepath.ToSlash(name), "/"))
+	for _, g := range galleries {
+		if strings.ToLower(filepath.ToSlash(g.Unbound)) == name {
+			return g
+		}
+		if rel, err := filepath.Rel(*sourcedir, g.Path); err == nil && strings.ToLower(filepath.ToSlash(rel)) == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// contactThumb is a JPEG encoded thumbnail on a contact sheet.
+type contactThumb struct {
+	data          []byte
+	width, height int
+}
+
+// ContactSheet lays out the thumbnails of the gallery on A4 pages
+// with the filename and capture date under each thumbnail.
+func ContactSheet(g *gallery.Gallery) *PDF {
+	columns := *contactcolumns
+	if columns < 1 {
+		columns = 1
+	}
+	cell := (PageWidth - 2*contactMargin - float64(columns-1)*contactGap) / float64(columns)
+	rows := int((PageHeight - 2*contactMargin - contactHeader + contactGap) / (cell + contactCaption + contactGap))
+	if rows < 1 {
+		rows = 1
+	}
+
+	// thumbnails are rendered at print resolution
+	pixels := int(cell * contactDPI / 72)
+	thumbs := make([]*contactThumb, len(g.Images))
+	async.Iter(len(g.Images), Workers(), func(i int) {
+		image := g.Images[i]
+		if image.Kind == gallery.KindVideo {
+			return
+		}
+		err := gallery.Files.Fetch(image.Raw)
+		if err != nil {
+			slog.Warn("contact sheet: image skipped", "file", image.Raw, "err", err)
+			return
+		}
+		m, err := imgproc.Load(image.Raw)
+		if err != nil {
+			slog.Warn("contact sheet: image skipped", "file", image.Raw, "err", err)
+			return
+		}
+		m = imgproc.ApplyPreset(m, g.Preset())
+		size := pixels
+		if w, h := m.Bounds().Dx(), m.Bounds().Dy(); w > h {
+			size = pixels * h / w
+		}
+		// the encoder writes RGB for everything but grayscale images
+		m = imaging.Clone(imgproc.Downscale(m, size))
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 85}); err != nil {
+			slog.Warn("contact sheet: image skipped", "file", image.Raw, "err", err)
+			return
+		}
+		thumbs[i] = &contactThumb{buf.Bytes(), m.Bounds().Dx(), m.Bounds().Dy()}
+	})
+
+	pdf := NewPDF()
+	perPage := rows * columns
+	pageCount := (len(g.Images) + perPage - 1) / perPage
+	for i, image := range g.Images {
+		if i%perPage == 0 {
+			pdf.NewPage()
+			pdf.Text(contactMargin, PageHeight-contactMargin-14, 14, g.Title)
+			pdf.Text(PageWidth-contactMargin-60, PageHeight-contactMargin-14, 9, fmt.Sprintf("Page %d of %d", i/perPage+1, pageCount))
+		}
+
+		row, column := (i%perPage)/columns, i%columns
+		x := contactMargin + float64(column)*(cell+contactGap)
+		top := PageHeight - contactMargin - contactHeader - float64(row)*(cell+contactCaption+contactGap)
+
+		if thumb := thumbs[i]; thumb != nil {
+			scale := cell / float64(thumb.width)
+			if h := cell / float64(thumb.height); h < scale {
+				scale = h
+			}
+			w, h := float64(thumb.width)*scale, float64(thumb.height)*scale
+			pdf.JPEG(thumb.data, thumb.width, thumb.height, x+(cell-w)/2, top-cell+(cell-h)/2, w, h)
+		} else {
+			pdf.Text(x+4, top-cell/2, 8, "no preview")
+		}
+
+		// roughly half of the font size per character
+		name := filepath.Base(image.Raw)
+		if max := int(cell / 3.5); len([]rune(name)) > max && max > 3 {
+			name = string([]rune(name)[:max-3]) + "..."
+		}
+		pdf.Text(x, top-cell-9, 7, name)
+		pdf.Text(x, top-cell-18, 7, image.Date().Format("2006-01-02 15:04"))
+	}
+	return pdf
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+var (
+	deploytarget = DeployFlags.String("deploy-target", "", "where deploy publishes the site, e.g. s3://bucket/prefix, gs://bucket/prefix, file:///var/www/site, ssh://user@host/path, rsync://host/module or git for a branch of the current repository; remote files missing from the site are deleted")
+	deployjobs   = DeployFlags.Int("deploy-jobs", 8, "number of parallel uploads")
+)
+
+// Deployer publishes files to a remote location,
+// names are slash separated and relative to the site root.
+type Deployer interface {
+	// List returns the names of the files on the remote.
+	List() ([]string, error)
+	// Sync uploads the files in upload from dir
+	// and deletes the remote files in remove.
+	Sync(dir string, upload, remove []string) error
+}
+
+// NewDeployer creates a deployer for the target url.
+func NewDeployer(target string) (Deployer, error) {
+	if target == "git" {
+		return &GitDeployer{Branch: *gitbranch, Remote: *gitremote}, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "s3", "gs", "file":
+		storage, err := NewStorage(target)
+		if err != nil {
+			return nil, err
+		}
+		return &StorageDeployer{storage}, nil
+	case "ssh", "rsync":
+		return NewRsyncDeployer(u)
+	case "":
+		return nil, fmt.Errorf("deploy target not specified")
+	}
+	return nil, fmt.Errorf("unsupported deploy target %q", target)
+}
+
+// StorageDeployer publishes the site into a bucket or a local directory.
+type StorageDeployer struct{ Storage }
+
+// Sync uploads and deletes the files in parallel.
+func (deployer *StorageDeployer) Sync(dir string, upload, remove []string) error {
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	async.Iter(len(upload), *deployjobs, func(i int) {
+		name := upload[i]
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			fail(err)
+			return
+		}
+		slog.Info("uploading", "file", name)
+		if err := deployer.Put(name, data); err != nil {
+			fail(err)
+		}
+	})
+
+	async.Iter(len(remove), *deployjobs, func(i int) {
+		slog.Info("deleting", "file", remove[i])
+		if err := deployer.Delete(remove[i]); err != nil {
+			fail(err)
+		}
+	})
+
+	for _, err := range errs {
+		slog.Error("deploy failed", "err", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("deploy failed: %d errors", len(errs))
+	}
+	return nil
+}
+
+// List returns the names of the deployed files.
+func (deployer *StorageDeployer) List() ([]string, error) {
+	files, err := deployer.Storage.List()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names, nil
+}
+
+// ContentType returns the MIME type of the file.
+func ContentType(name string) string {
+	RegisterMimeTypes()
+	if typ := mime.TypeByExtension(filepath.Ext(name)); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+// CacheControl returns how long browsers may cache the file,
+// pages and feeds change with every build and are revalidated.
+func CacheControl(name string) string {
+	switch filepath.Ext(name) {
+	case ".html", ".xml", ".json", ".geojson", ".txt", ".webmanifest":
+		return "public, max-age=0, must-revalidate"
+	case ".css", ".js":
+		return "public, max-age=3600"
+	}
+	return "public, max-age=86400"
+}
+
+// LocalFiles returns the files in dir as slash separated relative names,
+// the build manifest is not included.
+func LocalFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() == ManifestName {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// Deploy uploads the files in dir to the target
+// and deletes the remote files that don't exist locally.
+//
+// Checksums of the deployed files are recorded per target, later
+// deploys only upload the changed files and remove the deleted ones.
+func Deploy(dir, target string, deployer Deployer) error {
+	names, err := LocalFiles(dir)
+	if err != nil {
+		return err
+	}
+	checksums, err := Checksums(dir, names)
+	if err != nil {
+		return err
+	}
+
+	state, err := LoadDeployState(DeployStateName)
+	if err != nil {
+		return err
+	}
+
+	deployed, known := state[target]
+	if !known || *deployfull {
+		// without a record the remote is the only source of truth
+		remote, err := deployer.List()
+		if err != nil {
+			return err
+		}
+		deployed = map[string]string{}
+		for _, name := range remote {
+			deployed[name] = ""
+		}
+	}
+
+	var upload, remove []string
+	for _, name := range names {
+		if sum, ok := deployed[name]; !ok || sum != checksums[name] {
+			upload = append(upload, name)
+		}
+	}
+	for name := range deployed {
+		if _, ok := checksums[name]; !ok {
+			remove = append(remove, name)
+		}
+	}
+	sort.Strings(remove)
+
+	slog.Info("deploying", "changed", len(upload), "removed", len(remove))
+	if len(upload) == 0 && len(remove) == 0 {
+		return nil
+	}
+	if err := deployer.Sync(dir, upload, remove); err != nil {
+		return err
+	}
+
+	state[target] = checksums
+	return state.Save(DeployStateName)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/egonelbre/gallery/internal/async"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// DeployStateName is the file recording the deployed checksums,
+// it's kept outside of the output directory so it isn't published.
+const DeployStateName = ".deploy.json"
+
+var deployfull = DeployFlags.Bool("deploy-full", false, "ignore the recorded checksums and upload every file")
+
+// DeployState contains the checksums of the deployed files per target.
+type DeployState map[string]map[string]string
+
+// LoadDeployState loads the deploy state from path,
+// a missing file results in an empty state.
+func LoadDeployState(path string) (DeployState, error) {
+	state := DeployState{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return DeployState{}, fmt.Errorf("%v: %v", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the deploy state to path.
+func (state DeployState) Save(path string) error {
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	return imgproc.WriteFile(path, data)
+}
+
+// Checksums computes the SHA-256 of the files in dir.
+func Checksums(dir string, names []string) (map[string]string, error) {
+	sums := make([]string, len(names))
+	var mu sync.Mutex
+	var firstErr error
+
+	async.Iter(len(names), Workers(), func(i int) {
+		sum, err := fileChecksum(filepath.Join(dir, filepath.FromSlash(names[i])))
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+		sums[i] = sum
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	checksums := map[string]string{}
+	for i, name := range names {
+		checksums[name] = sums[i]
+	}
+	return checksums, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var (
+	sourcedir  = SourceFlags.String("source", "images", "directory containing the galleries, or an s3://bucket/prefix, gs://bucket/prefix or http(s) directory listing read through -source-cache")
+	outputdir  = OutputFlags.String("output", "public", "directory the site is generated into, or a file://, s3://bucket/prefix or gs://bucket/prefix location the build writes to directly")
+	thumbsdir  = OutputFlags.String("thumbs", "thumbs", "subdirectory of the output for thumbnails")
+	staticdirs = OutputFlags.String("static", "css", "comma separated directories copied into the output as is")
+)
+
+// Output returns the path of name in the output directory.
+func Output(name string) string {
+	return filepath.Join(*outputdir, name)
+}
+
+// StaticDirs returns the directories copied into the output.
+func StaticDirs() []string {
+	var dirs []string
+	for _, dir := range strings.Split(*staticdirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	if *lightbox && !containsString(dirs, LightboxDir) {
+		dirs = append(dirs, LightboxDir)
+	}
+	return dirs
+}
+
+// containsString returns whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Errors collects the errors of a task that continues after a failure.
+type Errors []error
+
+// Add appends err, nil errors are ignored.
+func (errs *Errors) Add(err error) {
+	if err != nil {
+		*errs = append(*errs, err)
+	}
+}
+
+// Err returns the collected errors or nil when there are none.
+func (errs Errors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (errs Errors) Error() string {
+	var xs []string
+	for _, err := range errs {
+		xs = append(xs, err.Error())
+	}
+	return strings.Join(xs, "\n")
+}
+
+// failures collects the failures of the current build.
+var failures Failures
+
+// Failure is a step of the build that failed for a file.
+type Failure struct {
+	Stage string
+	File  string
+	Err   error
+}
+
+// Failures collects the failures of a build,
+// the build continues with the remaining files.
+type Failures struct {
+	mu   sync.Mutex
+	list []Failure
+}
+
+// Add logs and records err, nil errors are ignored.
+func (failures *Failures) Add(stage, file string, err error) {
+	if err == nil {
+		return
+	}
+	if file != "" {
+		slog.Error(stage+" failed", "file", file, "err", err)
+	} else {
+		slog.Error(stage+" failed", "err", err)
+	}
+
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	failures.list = append(failures.list, Failure{stage, file, err})
+}
+
+// Reset forgets the failures of the previous build.
+func (failures *Failures) Reset() {
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	failures.list = nil
+}
+
+// Err prints a summary of the failures to stderr
+// and returns an error when there were any.
+func (failures *Failures) Err() error {
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	if len(failures.list) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(failures.list, func(i, k int) bool {
+		return failures.list[i].File < failures.list[k].File
+	})
+	fmt.Fprintf(os.Stderr, "\n%d failures:\n", len(failures.list))
+	for _, failure := range failures.list {
+		if failure.File != "" {
+			fmt.Fprintf(os.Stderr, "  %v: %v: %v\n", failure.Stage, failure.File, failure.Err)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %v: %v\n", failure.Stage, failure.Err)
+		}
+	}
+	return fmt.Errorf("build failed with %d failures", len(failures.list))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/xml"
+	"html/template"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var (
+	baseurl        = BuildFlags.String("base-url", "", "absolute url of the site, e.g. https://example.com")
+	feedsize       = BuildFlags.Int("feed-size", 50, "number of entries in feeds")
+	galleryfeeds   = BuildFlags.Bool("gallery-feeds", false, "create a feed for each gallery")
+	feedauthorname = BuildFlags.String("feed-author", "Egon Elbre", "author name used in feeds")
+)
+
+// AbsoluteURL joins link with the configured base url.
+func AbsoluteURL(link string) string {
+	return strings.TrimSuffix(*baseurl, "/") + link
+}
+
+// Atom is an Atom feed document.
+type Atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  AtomAuthor  `xml:"author"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Links     []AtomLink `xml:"link"`
+	Summary   string     `xml:"summary,omitempty"`
+	Content   AtomText   `xml:"content"`
+}
+
+type AtomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedItem is an image together with the gallery it belongs to.
+type feedItem struct {
+	Gallery *gallery.Gallery
+	Image   *gallery.Image
+}
+
+// CreateFeeds writes the site feed and, when enabled, a feed for every gallery.
+func CreateFeeds(galleries map[string]*gallery.Gallery) {
+	if *baseurl == "" {
+		slog.Warn("feeds use relative links: -base-url not specified")
+	}
+
+	var all []feedItem
+	for _, g := range galleries {
+		var items []feedItem
+		for _, image := range g.Images {
+			items = append(items, feedItem{g, image})
+		}
+		all = append(all, items...)
+
+		if *galleryfeeds {
+			link := g.PageLink() + "/"
+			err := WriteFeed(filepath.Join(g.Unbound, "feed.xml"), g.Title, link, items)
+			if err != nil {
+				failures.Add("feed", filepath.Join(g.Unbound, "feed.xml"), err)
+			}
+		}
+	}
+
+	if err := WriteFeed("feed.xml", "Galleries", "/", all); err != nil {
+		failures.Add("feed", "feed.xml", err)
+	}
+}
+
+// WriteFeed writes the most recently added items as an Atom feed.
+func WriteFeed(name, title, link string, items []feedItem) error {
+	sort.SliceStable(items, func(i, k int) bool {
+		a, b := items[i].Image.Added(), items[k].Image.Added()
+		if a.Equal(b) {
+			return items[i].Image.PageLink() < items[k].Image.PageLink()
+		}
+		return a.After(b)
+	})
+	if len(items) > *feedsize {
+		items = items[:*feedsize]
+	}
+
+	feedlink := AbsoluteURL("/" + filepath.ToSlash(name))
+	feed := Atom{
+		ID:     feedlink,
+		Title:  title,
+		Author: AtomAuthor{Name: *feedauthorname},
+		Links: []AtomLink{
+			{Rel: "self", Href: feedlink, Type: "application/atom+xml"},
+			{Rel: "alternate", Href: AbsoluteURL(link), Type: "text/html"},
+		},
+	}
+
+	updated := time.Time{}
+	for _, item := range items {
+		image := item.Image
+		if image.Added().After(updated) {
+			updated = image.Added()
+		}
+
+		content := `<p><a href="` + template.HTMLEscapeString(AbsoluteURL(image.PageLink())) + `">` +
+			`<img src="` + template.HTMLEscapeString(AbsoluteURL(image.ThumbLink())) + `" alt="` + template.HTMLEscapeString(image.Title) + `"></a></p>`
+		if image.Caption != "" {
+			content += "<p>" + template.HTMLEscapeString(image.Caption) + "</p>"
+		}
+
+		feed.Entries = append(feed.Entries, AtomEntry{
+			ID:        AbsoluteURL(image.PageLink()),
+			Title:     item.Gallery.Title + ": " + image.Title,
+			Updated:   image.Added().UTC().Format(time.RFC3339),
+			Published: image.Date().UTC().Format(time.RFC3339),
+			Links:     []AtomLink{{Rel: "alternate", Href: AbsoluteURL(image.PageLink())}},
+			Summary:   image.Caption,
+			Content:   AtomText{Type: "html", Body: content},
+		})
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return WriteOutput(Output(name), append([]byte(xml.Header), data...))
+}
@@ -0,0 +1,60 @@
+package main
+
+import "flag"
+
+// The flags are registered in groups, each command accepts the flags
+// of the groups it lists in addition to CommonFlags.
+var (
+	// CommonFlags are accepted by every command.
+	CommonFlags = newFlagGroup("common")
+	// SourceFlags locate and read the galleries and their sources.
+	SourceFlags = newFlagGroup("source")
+	// OutputFlags locate the generated site.
+	OutputFlags = newFlagGroup("output")
+	// BuildFlags control how the site is generated.
+	BuildFlags = newFlagGroup("build")
+
+	ServeFlags        = newFlagGroup("serve")
+	CleanFlags        = newFlagGroup("clean")
+	DeployFlags       = newFlagGroup("deploy")
+	ImportFlags       = newFlagGroup("import")
+	ContactSheetFlags = newFlagGroup("contact-sheet")
+)
+
+// FlagGroups lists all the flag groups.
+var FlagGroups = []*flag.FlagSet{
+	CommonFlags, SourceFlags, OutputFlags, BuildFlags,
+	ServeFlags, CleanFlags, DeployFlags, ImportFlags, ContactSheetFlags,
+}
+
+// newFlagGroup creates a group of flags, it's only used for registering
+// the flags, the arguments are parsed with MergeFlags.
+func newFlagGroup(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// MergeFlags returns a flag set with the flags of the groups,
+// the flags share their values with the groups.
+func MergeFlags(name string, groups ...*flag.FlagSet) *flag.FlagSet {
+	merged := flag.NewFlagSet(name, flag.ExitOnError)
+	for _, group := range groups {
+		group.VisitAll(func(f *flag.Flag) {
+			if merged.Lookup(f.Name) == nil {
+				merged.Var(f.Value, f.Name, f.Usage)
+				merged.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+	}
+	return merged
+}
+
+// LookupFlag returns the flag with the name from any group,
+// nil when there isn't one.
+func LookupFlag(name string) *flag.Flag {
+	for _, group := range FlagGroups {
+		if f := group.Lookup(name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+// flickrPhotostream is the gallery of the photos that aren't in any album.
+const flickrPhotostream = "Photostream"
+
+// flickrPhoto is the photo_<id>.json of a Flickr export.
+type flickrPhoto struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// DateTaken is formatted as 2006-01-02 15:04:05.
+	DateTaken string `json:"date_taken"`
+	Tags      []struct {
+		Tag string `json:"tag"`
+	} `json:"tags"`
+
+	// Archive and Path locate the photo file.
+	Archive fs.FS  `json:"-"`
+	Path    string `json:"-"`
+}
+
+// flickrAlbums is the albums.json of a Flickr export.
+type flickrAlbums struct {
+	Albums []flickrAlbum `json:"albums"`
+}
+
+// flickrAlbum lists the photos of an album in the album order.
+type flickrAlbum struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Created is a unix timestamp.
+	Created string `json:"created"`
+	// CoverPhoto is the URL of the cover, ending with the photo id.
+	CoverPhoto string   `json:"cover_photo"`
+	Photos     []string `json:"photos"`
+}
+
+// flickrPhotoID returns the id from the name of a photo file, the exports
+// name them like title_1234567890_o.jpg or 1234567890_o.jpg.
+func flickrPhotoID(name string, photos map[string]*flickrPhoto) string {
+	parts := strings.Split(gallery.ReplaceExt(name, ""), "_")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if _, ok := photos[parts[i]]; ok {
+			return parts[i]
+		}
+	}
+	return ""
+}
+
+// ImportFlickr imports a Flickr data export, the archives with the photos
+// and the archive with the account JSON. Each album becomes a gallery in
+// the album order and the photos get sidecars with their title, description,
+// tags and the time they were taken. Photos that aren't in any album are
+// imported into a Photostream gallery. The galleries are imported as private,
+// so they can be reviewed before publishing.
+func ImportFlickr(dest string, exports []string) error {
+	archives, closeArchives, err := openArchives(exports)
+	if err != nil {
+		return err
+	}
+	defer closeArchives()
+
+	photos := map[string]*flickrPhoto{}
+	var albums []flickrAlbum
+	type file struct {
+		archive fs.FS
+		path    string
+	}
+	var files []file
+
+	for _, archive := range archives {
+		err := fs.WalkDir(archive, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if name != "." && gallery.IsIgnored(entry.Name()) {
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				return nil
+			}
+
+			base := entry.Name()
+			switch {
+			case base == "albums.json":
+				data, err := fs.ReadFile(archive, name)
+				if err != nil {
+					return err
+				}
+				var list flickrAlbums
+				if err := json.Unmarshal(data, &list); err != nil {
+					return fmt.Errorf("%v: %v", name, err)
+				}
+				albums = append(albums, list.Albums...)
+			case strings.HasPrefix(base, "photo_") && path.Ext(base) == ".json":
+				data, err := fs.ReadFile(archive, name)
+				if err != nil {
+					return err
+				}
+				photo := &flickrPhoto{}
+				if err := json.Unmarshal(data, photo); err != nil {
+					slog.Warn("invalid photo metadata", "file", name, "error", err)
+					return nil
+				}
+				if photo.ID != "" {
+					photos[photo.ID] = photo
+				}
+			case gallery.IsSource(name):
+				files = append(files, file{archive, name})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(photos) == 0 {
+		return fmt.Errorf("no photo metadata found, include the account data archive of the export")
+	}
+
+	for _, file := range files {
+		id := flickrPhotoID(path.Base(file.path), photos)
+		if id == "" {
+			slog.Warn("photo without metadata", "file", file.path)
+			continue
+		}
+		photos[id].Archive, photos[id].Path = file.archive, file.path
+	}
+
+	inAlbum := map[string]bool{}
+	for _, album := range albums {
+		for _, id := range album.Photos {
+			inAlbum[id] = true
+		}
+	}
+	var stream []string
+	for id := range photos {
+		if !inAlbum[id] {
+			stream = append(stream, id)
+		}
+	}
+	if len(stream) > 0 {
+		sort.Slice(stream, func(i, k int) bool {
+			return photos[stream[i]].DateTaken < photos[stream[k]].DateTaken
+		})
+		albums = append(albums, flickrAlbum{Title: flickrPhotostream, Photos: stream})
+	}
+
+	used := map[string]bool{}
+	for _, album := range albums {
+		target := filepath.Join(dest, importDirName(album.Title, used))
+
+		config := ImportedGallery{
+			Title:       album.Title,
+			Description: strings.TrimSpace(album.Description),
+			Visibility:  gallery.VisibilityPrivate,
+		}
+		if seconds, err := strconv.ParseInt(album.Created, 10, 64); err == nil && seconds > 0 {
+			config.Date = time.Unix(seconds, 0).Format("2006-01-02")
+		}
+		cover := path.Base(album.CoverPhoto)
+
+		var order []string
+		copied, skipped := 0, 0
+		for _, id := range album.Photos {
+			photo, ok := photos[id]
+			if !ok || photo.Path == "" {
+				slog.Warn("album photo not found", "album", album.Title, "photo", id)
+				continue
+			}
+
+			name := path.Base(photo.Path)
+			order = append(order, name)
+			if id == cover {
+				config.Cover = name
+			}
+
+			source := filepath.Join(target, name)
+			taken, err := time.ParseInLocation(gallery.SidecarDate, photo.DateTaken, time.Local)
+			if err != nil {
+				taken = time.Time{}
+			}
+			ok, err = importFile(photo.Archive, photo.Path, source, taken)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+			copied++
+
+			sidecar := gallery.Sidecar{
+				Title:   strings.TrimSpace(photo.Name),
+				Caption: strings.TrimSpace(photo.Description),
+			}
+			if !taken.IsZero() {
+				sidecar.Date = photo.DateTaken
+			}
+			for _, tag := range photo.Tags {
+				sidecar.Tags = append(sidecar.Tags, tag.Tag)
+			}
+			if err := writeSidecar(source, sidecar); err != nil {
+				return err
+			}
+		}
+
+		if copied == 0 {
+			slog.Info("album already imported", "folder", target, "skipped", skipped)
+			continue
+		}
+		if err := writeGalleryConfig(target, config); err != nil {
+			return err
+		}
+		if album.ID != "" {
+			if err := writeOrder(target, order); err != nil {
+				return err
+			}
+		}
+		slog.Info("album imported", "folder", target, "photos", copied, "skipped", skipped)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"sort"
+
+	"github.com/egonelbre/gallery"
+)
+
+var photomap = BuildFlags.Bool("map", false, "create a map of geotagged images, requires -gps-privacy=false")
+
+// GeoJSON types for the photo locations.
+type (
+	FeatureCollection struct {
+		Type     string     `json:"type"`
+		Features []*Feature `json:"features"`
+	}
+
+	Feature struct {
+		Type       string            `json:"type"`
+		Geometry   Point             `json:"geometry"`
+		Properties FeatureProperties `json:"properties"`
+	}
+
+	Point struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	}
+
+	FeatureProperties struct {
+		Title   string `json:"title"`
+		Page    string `json:"page"`
+		Thumb   string `json:"thumb"`
+		Gallery string `json:"gallery"`
+		Date    string `json:"date,omitempty"`
+	}
+)
+
+// LocatedImages returns the geotagged images of gallery.
+func LocatedImages(g *gallery.Gallery) []*gallery.Image {
+	var located []*gallery.Image
+	for _, image := range g.Images {
+		if image.Location() != nil {
+			located = append(located, image)
+		}
+	}
+	return located
+}
+
+// MapEnabled returns whether the map page is created.
+func MapEnabled() bool { return *photomap && !*gpsprivacy }
+
+// CreateMap writes photos.geojson and the map page.
+func CreateMap(galleries map[string]*gallery.Gallery) error {
+	if !*photomap {
+		return nil
+	}
+	if !MapEnabled() {
+		slog.Warn("map skipped: -gps-privacy is enabled")
+		return nil
+	}
+
+	collection := FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+	for _, g := range galleries {
+		for _, image := range LocatedImages(g) {
+			location := image.Location()
+			feature := &Feature{
+				Type: "Feature",
+				Geometry: Point{
+					Type:        "Point",
+					Coordinates: [2]float64{location.Longitude, location.Latitude},
+				},
+				Properties: FeatureProperties{
+					Title:   image.Title,
+					Page:    image.PageLink(),
+					Thumb:   image.ThumbLink(),
+					Gallery: g.Title,
+				},
+			}
+			if !image.Metadata.Taken.IsZero() {
+				feature.Properties.Date = image.Metadata.Taken.Format("2006-01-02")
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+	sort.Slice(collection.Features, func(i, k int) bool {
+		return collection.Features[i].Properties.Page < collection.Features[k].Properties.Page
+	})
+
+	data, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	if err := WriteOutput(Output("photos.geojson"), data); err != nil {
+		return err
+	}
+
+	CreatePage(filepath.Join("map", "index.html"), "map.html", map[string]interface{}{
+		"Title": "Map",
+	})
+	return nil
+}
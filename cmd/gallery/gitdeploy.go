@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	git       = DeployFlags.String("git", "git", "path to git for deploying to a branch")
+	gitbranch = DeployFlags.String("git-branch", "gh-pages", "branch the site is committed to with -deploy-target git")
+	gitremote = DeployFlags.String("git-remote", "origin", "remote the branch is pushed to, empty disables pushing")
+)
+
+// GitDeployer commits the site to a branch of the current repository,
+// e.g. for GitHub Pages, without touching the working tree.
+type GitDeployer struct {
+	Branch string
+	Remote string
+}
+
+// ref returns the full name of the branch.
+func (deployer *GitDeployer) ref() string { return "refs/heads/" + deployer.Branch }
+
+// List returns the files committed to the branch.
+func (deployer *GitDeployer) List() ([]string, error) {
+	if _, err := deployer.git(nil, nil, "rev-parse", "--verify", "--quiet", deployer.ref()); err != nil {
+		return nil, nil
+	}
+
+	out, err := deployer.git(nil, nil, "ls-tree", "-r", "-z", "--name-only", deployer.ref())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" && name != ".nojekyll" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Sync commits the changes on top of the branch and pushes it.
+func (deployer *GitDeployer) Sync(dir string, upload, remove []string) error {
+	index, err := ioutil.TempFile("", "gallery-index-*")
+	if err != nil {
+		return err
+	}
+	index.Close()
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	parent := ""
+	if out, err := deployer.git(env, nil, "rev-parse", "--verify", "--quiet", deployer.ref()); err == nil {
+		parent = strings.TrimSpace(string(out))
+		if _, err := deployer.git(env, nil, "read-tree", parent); err != nil {
+			return err
+		}
+	}
+
+	if len(upload) > 0 {
+		stdin := strings.NewReader(strings.Join(upload, "\x00") + "\x00")
+		if _, err := deployer.git(env, stdin, "--work-tree="+dir, "update-index", "--add", "-z", "--stdin"); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		stdin := strings.NewReader(strings.Join(remove, "\x00") + "\x00")
+		if _, err := deployer.git(env, stdin, "update-index", "--force-remove", "-z", "--stdin"); err != nil {
+			return err
+		}
+	}
+
+	// disable Jekyll processing on GitHub Pages, which hides files starting with "_"
+	empty, err := deployer.git(env, strings.NewReader(""), "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	cacheinfo := "100644," + strings.TrimSpace(string(empty)) + ",.nojekyll"
+	if _, err := deployer.git(env, nil, "update-index", "--add", "--cacheinfo", cacheinfo); err != nil {
+		return err
+	}
+
+	out, err := deployer.git(env, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+	tree := strings.TrimSpace(string(out))
+
+	if parent != "" {
+		out, err := deployer.git(env, nil, "rev-parse", parent+"^{tree}")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(out)) == tree {
+			slog.Info("nothing to deploy")
+			return nil
+		}
+	}
+
+	args := []string{"commit-tree", tree, "-m", "Deploy site"}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	out, err = deployer.git(env, nil, args...)
+	if err != nil {
+		return err
+	}
+	commit := strings.TrimSpace(string(out))
+
+	if _, err := deployer.git(env, nil, "update-ref", deployer.ref(), commit); err != nil {
+		return err
+	}
+	slog.Info("committed", "commit", commit, "branch", deployer.Branch)
+
+	if deployer.Remote == "" {
+		return nil
+	}
+	_, err = deployer.git(nil, nil, "push", deployer.Remote, deployer.ref()+":"+deployer.ref())
+	return err
+}
+
+// git runs a git command with the additional environment.
+func (deployer *GitDeployer) git(env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(*git, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %v: %v: %s", strings.Join(args, " "), err, stderr.Bytes())
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/egonelbre/gallery"
+)
+
+var hashnames = BuildFlags.Bool("hash-names", false, "include a hash of the source in image file names, e.g. IMG_1234.ab12cd.jpg")
+
+// HashLength is the number of hex digits of the source hash in file names.
+const HashLength = 6
+
+// HashNames adds the source hash to the published file names of image,
+// so an edited photo gets a new URL.
+func HashNames(image *gallery.Image) {
+	hash := manifest.SourceHash(image.Raw)
+	if len(hash) < HashLength {
+		return
+	}
+	hash = hash[:HashLength]
+
+	for _, name := range []*string{&image.Path, &image.Thumb, &image.Poster, &image.Preview, &image.WebP, &image.ThumbWebP} {
+		if *name != "" {
+			*name = HashedName(*name, hash)
+		}
+	}
+}
+
+// HashedName inserts hash before the extension of name.
+func HashedName(name, hash string) string {
+	return gallery.ReplaceExt(name, "."+hash+filepath.Ext(name))
+}
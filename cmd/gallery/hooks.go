@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"plugin"
+	"sort"
+	"strings"
+
+	"github.com/egonelbre/gallery"
+)
+
+var plugins = BuildFlags.String("plugins", "", "comma separated Go plugins (.so) that register build hooks")
+
+var hookcommands = HookCommands{}
+
+func init() {
+	BuildFlags.Var(&hookcommands, "hooks", "shell commands run at build points, e.g. after-build=./publish.sh; points are after-scan, before-image, after-image, before-page and after-build")
+}
+
+// HookPoints are the names of the build points commands can be run at.
+var HookPoints = []string{"after-scan", "before-image", "after-image", "before-page", "after-build"}
+
+// HookCommands maps hook points to shell commands.
+type HookCommands map[string]string
+
+func (hooks *HookCommands) String() string {
+	var xs []string
+	for point, command := range *hooks {
+		xs = append(xs, point+"="+command)
+	}
+	sort.Strings(xs)
+	return strings.Join(xs, ",")
+}
+
+func (hooks *HookCommands) Set(value string) error {
+	*hooks = HookCommands{}
+	for _, x := range strings.Split(value, ",") {
+		if strings.TrimSpace(x) == "" {
+			continue
+		}
+		point, command, ok := strings.Cut(x, "=")
+		point = strings.TrimSpace(point)
+		if !ok || !containsString(HookPoints, point) {
+			return fmt.Errorf("invalid hook %q, expected point=command", x)
+		}
+		(*hooks)[point] = strings.TrimSpace(command)
+	}
+	return nil
+}
+
+// LoadHooks opens the plugins and registers the hook commands.
+func LoadHooks() error {
+	for _, path := range strings.Split(*plugins, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		// plugins register their hooks in init
+		if _, err := plugin.Open(path); err != nil {
+			return err
+		}
+	}
+
+	for point, command := range hookcommands {
+		command := command
+		switch point {
+		case "after-scan":
+			gallery.AfterScan = append(gallery.AfterScan, func(galleries map[string]*gallery.Gallery) error {
+				return RunHook(command)
+			})
+		case "before-image", "after-image":
+			hook := func(g *gallery.Gallery, image *gallery.Image) error {
+				if err := gallery.Files.Fetch(image.Raw); err != nil {
+					return err
+				}
+				return RunHook(command, "GALLERY_NAME="+g.Name, "GALLERY_IMAGE="+image.Raw, "GALLERY_PATH="+Output(image.Path))
+			}
+			if point == "before-image" {
+				gallery.BeforeImage = append(gallery.BeforeImage, hook)
+			} else {
+				gallery.AfterImage = append(gallery.AfterImage, hook)
+			}
+		case "before-page":
+			gallery.BeforePage = append(gallery.BeforePage, func(name, template string, data map[string]interface{}) error {
+				return RunHook(command, "GALLERY_PAGE="+Output(name), "GALLERY_TEMPLATE="+template)
+			})
+		case "after-build":
+			gallery.AfterBuild = append(gallery.AfterBuild, func(galleries map[string]*gallery.Gallery, output string) error {
+				return RunHook(command)
+			})
+		}
+	}
+	return nil
+}
+
+// RunHook runs the shell command with the source and output directories,
+// and the extra variables, in the environment.
+func RunHook(command string, env ...string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "GALLERY_SOURCE="+*sourcedir, "GALLERY_OUTPUT="+*outputdir)
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q: %v", command, err)
+	}
+	return nil
+}
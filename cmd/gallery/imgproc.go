@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var progressive = BuildFlags.Bool("progressive", false, "encode large JPEG renditions as progressive")
+var filter = BuildFlags.String("filter", "catmullrom", "resampling filter for downscaling: nearest, bilinear, catmullrom or lanczos")
+var sharpen = BuildFlags.Float64("sharpen", 0, "amount of unsharp mask applied after downscaling photos, e.g. 0.5, 0 disables sharpening")
+var sharpenradius = BuildFlags.Float64("sharpen-radius", 0.6, "radius of the unsharp mask in pixels")
+var processorname = SourceFlags.String("processor", "go", "image processing backend: go or vips; vips renders the renditions straight from the sources when they need no presets, crops, pads or sharpening, otherwise it only decodes them")
+
+// processor decodes, resizes and encodes photos, it's set from -processor.
+var processor imgproc.Processor = imgproc.Go{}
+
+func init() {
+	SourceFlags.StringVar(&imgproc.HEIFConvert, "heif-convert", imgproc.HEIFConvert, "path to heif-convert for decoding HEIC/HEIF")
+	SourceFlags.StringVar(&imgproc.DCRaw, "dcraw", imgproc.DCRaw, "path to dcraw for developing camera RAW files, by default the embedded preview is used")
+	BuildFlags.StringVar(&imgproc.FaceDetector, "face-detector", imgproc.FaceDetector, "external face detector command, invoked with an image path and printing \"x y w h\" per face; by default faces are located by skin tone")
+
+	BuildFlags.StringVar(&imgproc.CWebP, "cwebp", imgproc.CWebP, "path to cwebp encoder")
+	BuildFlags.StringVar(&imgproc.GIF2WebP, "gif2webp", imgproc.GIF2WebP, "path to gif2webp encoder")
+	BuildFlags.StringVar(&imgproc.JPEGTran, "jpegtran", imgproc.JPEGTran, "path to jpegtran for progressive JPEG encoding")
+	BuildFlags.StringVar(&imgproc.AVIFEnc, "avifenc", imgproc.AVIFEnc, "path to avifenc encoder")
+	SourceFlags.StringVar(&imgproc.FFmpeg, "ffmpeg", imgproc.FFmpeg, "path to ffmpeg for processing videos")
+	SourceFlags.StringVar(&imgproc.VIPSPath, "vips", imgproc.VIPSPath, "path to vips for the vips processor")
+	SourceFlags.StringVar(&imgproc.FFprobe, "ffprobe", imgproc.FFprobe, "path to ffprobe for inspecting videos")
+
+	BuildFlags.IntVar(&imgproc.JPEGQuality, "jpeg-quality", imgproc.JPEGQuality, "JPEG encoding quality (1-100)")
+	BuildFlags.IntVar(&imgproc.WebPQuality, "webp-quality", imgproc.WebPQuality, "WebP encoding quality")
+	BuildFlags.IntVar(&imgproc.AVIFQuality, "avif-quality", imgproc.AVIFQuality, "AVIF encoding quality (0-100)")
+	BuildFlags.IntVar(&imgproc.AVIFSpeed, "avif-speed", imgproc.AVIFSpeed, "AVIF encoder speed (0 slowest - 10 fastest)")
+
+	BuildFlags.Var(&jpegsizequality, "jpeg-quality-sizes", "per size JPEG quality overrides, e.g. 256=75,2048=88")
+	BuildFlags.Var(&sizefilters, "size-filters", "per size resampling filter overrides, e.g. 256=bilinear,2048=lanczos")
+	BuildFlags.Var(&sizesharpen, "sharpen-sizes", "per size sharpening amount overrides, e.g. 256=0.8,2048=0.3")
+	BuildFlags.Var(&sizemodes, "size-modes", "per size resize modes: fit keeps the proportions, fill crops to uniform tiles and pad letterboxes, e.g. 256=fill:1:1,1024=pad:3:2")
+}
+
+// QualityList contains JPEG quality overrides for specific rendition sizes.
+type QualityList map[int]int
+
+func (list *QualityList) String() string {
+	var xs []string
+	for size, quality := range *list {
+		xs = append(xs, fmt.Sprintf("%d=%d", size, quality))
+	}
+	sort.Strings(xs)
+	return strings.Join(xs, ",")
+}
+
+func (list *QualityList) Set(value string) error {
+	*list = QualityList{}
+	for _, x := range strings.Split(value, ",") {
+		if strings.TrimSpace(x) == "" {
+			continue
+		}
+		var size, quality int
+		if _, err := fmt.Sscanf(strings.TrimSpace(x), "%d=%d", &size, &quality); err != nil {
+			return fmt.Errorf("invalid quality override %q, expected size=quality", x)
+		}
+		if quality < 1 || quality > 100 {
+			return fmt.Errorf("invalid quality %d for size %d", quality, size)
+		}
+		(*list)[size] = quality
+	}
+	return nil
+}
+
+var jpegsizequality = QualityList{}
+
+// JPEGQuality returns the JPEG quality for a rendition size.
+func JPEGQuality(size int) int {
+	if quality, ok := jpegsizequality[size]; ok {
+		return quality
+	}
+	return imgproc.JPEGQuality
+}
+
+// FilterList contains resampling filter overrides for specific rendition sizes.
+type FilterList map[int]string
+
+func (list *FilterList) String() string {
+	var xs []string
+	for size, filter := range *list {
+		xs = append(xs, fmt.Sprintf("%d=%s", size, filter))
+	}
+	sort.Strings(xs)
+	return strings.Join(xs, ",")
+}
+
+func (list *FilterList) Set(value string) error {
+	*list = FilterList{}
+	for _, x := range strings.Split(value, ",") {
+		if strings.TrimSpace(x) == "" {
+			continue
+		}
+		key, filter, ok := strings.Cut(strings.TrimSpace(x), "=")
+		size, err := strconv.Atoi(key)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid filter override %q, expected size=filter", x)
+		}
+		if _, ok := imgproc.Filters[filter]; !ok {
+			return fmt.Errorf("unknown filter %q for size %d", filter, size)
+		}
+		(*list)[size] = filter
+	}
+	return nil
+}
+
+var sizefilters = FilterList{}
+
+// FilterFor returns the resampling filter for a rendition size.
+func FilterFor(size int) string {
+	if filter, ok := sizefilters[size]; ok {
+		return filter
+	}
+	return *filter
+}
+
+// AmountList contains sharpening amount overrides for specific rendition sizes.
+type AmountList map[int]float64
+
+func (list *AmountList) String() string {
+	var xs []string
+	for size, amount := range *list {
+		xs = append(xs, fmt.Sprintf("%d=%g", size, amount))
+	}
+	sort.Strings(xs)
+	return strings.Join(xs, ",")
+}
+
+func (list *AmountList) Set(value string) error {
+	*list = AmountList{}
+	for _, x := range strings.Split(value, ",") {
+		if strings.TrimSpace(x) == "" {
+			continue
+		}
+		var size int
+		var amount float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(x), "%d=%g", &size, &amount); err != nil {
+			return fmt.Errorf("invalid sharpening override %q, expected size=amount", x)
+		}
+		if amount < 0 {
+			return fmt.Errorf("invalid sharpening amount %g for size %d", amount, size)
+		}
+		(*list)[size] = amount
+	}
+	return nil
+}
+
+var sizesharpen = AmountList{}
+
+// SharpenAmount returns the amount of the unsharp mask for the rendition size.
+func SharpenAmount(size int) float64 {
+	if amount, ok := sizesharpen[size]; ok {
+		return amount
+	}
+	return *sharpen
+}
+
+// Sharpen applies the unsharp mask configured for the rendition size to m.
+func Sharpen(m image.Image, size int) image.Image {
+	return imgproc.Sharpen(m, SharpenAmount(size), *sharpenradius)
+}
+
+// SizeMode describes how a rendition is fitted into its size.
+type SizeMode struct {
+	// Mode is "fit", "fill" or "pad".
+	Mode string
+	// Aspect is the width to height ratio of fill and pad.
+	Aspect imgproc.Aspect
+}
+
+// SizeModes contains the resize modes for specific rendition sizes.
+type SizeModes map[int]SizeMode
+
+func (modes *SizeModes) String() string {
+	var xs []string
+	for size, mode := range *modes {
+		if mode.Mode == "fit" {
+			xs = append(xs, fmt.Sprintf("%d=fit", size))
+		} else {
+			xs = append(xs, fmt.Sprintf("%d=%s:%s", size, mode.Mode, mode.Aspect.String()))
+		}
+	}
+	sort.Strings(xs)
+	return strings.Join(xs, ",")
+}
+
+func (modes *SizeModes) Set(value string) error {
+	*modes = SizeModes{}
+	for _, x := range strings.Split(value, ",") {
+		if strings.TrimSpace(x) == "" {
+			continue
+		}
+		key, spec, ok := strings.Cut(strings.TrimSpace(x), "=")
+		size, err := strconv.Atoi(key)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid size mode %q, expected size=mode", x)
+		}
+
+		name, aspect, _ := strings.Cut(spec, ":")
+		mode := SizeMode{Mode: name, Aspect: 1}
+		switch name {
+		case "fit":
+			mode.Aspect = 0
+		case "fill", "pad":
+			if aspect != "" {
+				if err := mode.Aspect.Set(aspect); err != nil {
+					return fmt.Errorf("size %d: %v", size, err)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown mode %q for size %d, expected fit, fill or pad", name, size)
+		}
+		(*modes)[size] = mode
+	}
+	return nil
+}
+
+var sizemodes = SizeModes{}
+
+// ModeFor returns the resize mode of a rendition size,
+// the thumbnail is cropped to -thumb-aspect unless it has a mode.
+func ModeFor(size int) SizeMode {
+	if mode, ok := sizemodes[size]; ok {
+		return mode
+	}
+	if size == sizes.Thumb() && thumbaspect > 0 {
+		return SizeMode{Mode: "fill", Aspect: thumbaspect}
+	}
+	return SizeMode{Mode: "fit"}
+}
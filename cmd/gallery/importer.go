@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+	"gopkg.in/yaml.v2"
+)
+
+// Importers convert the exports of photo services into galleries
+// in the destination directory.
+var Importers = map[string]func(dest string, exports []string) error{
+	"flickr":    ImportFlickr,
+	"lightroom": ImportLightroom,
+	"takeout":   ImportTakeout,
+}
+
+// ImportCommand imports an export into the source directory.
+func ImportCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: import <%v> <export>...", strings.Join(importerNames(), "|"))
+	}
+	importer, ok := Importers[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown import format %q, expected one of %v", args[0], strings.Join(importerNames(), ", "))
+	}
+	return importer(*sourcedir, args[1:])
+}
+
+// importerNames returns the sorted names of the importers.
+func importerNames() []string {
+	var names []string
+	for name := range Importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openArchives opens the extracted export directories and ZIP files,
+// close releases the ZIP files.
+func openArchives(paths []string) (archives []fs.FS, close func(), err error) {
+	var closers []io.Closer
+	close = func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+	for _, path := range paths {
+		archive, closer, err := openArchive(path)
+		if err != nil {
+			close()
+			return nil, nil, err
+		}
+		closers = append(closers, closer)
+		archives = append(archives, archive)
+	}
+	return archives, close, nil
+}
+
+// openArchive opens an extracted export directory or a ZIP file.
+func openArchive(path string) (fs.FS, io.Closer, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return archive, archive, nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, err
+	}
+	return os.DirFS(path), io.NopCloser(nil), nil
+}
+
+// ImportedGallery is the gallery.yaml written for an imported album.
+type ImportedGallery struct {
+	Title       string `yaml:"title,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Date        string `yaml:"date,omitempty"`
+	Cover       string `yaml:"cover,omitempty"`
+	Visibility  string `yaml:"visibility"`
+}
+
+// importDirName returns a directory name for an album title,
+// names that are already used get a numeric suffix.
+func importDirName(title string, used map[string]bool) string {
+	name := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '-'
+		}
+		return r
+	}, title))
+	name = strings.Trim(name, ".")
+	if name == "" {
+		name = "album"
+	}
+
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// importFile copies name from the archive to dst, keeping the modification
+// time unless modtime is set. Existing files are kept, hence importing the
+// same export again only adds the missing photos.
+func importFile(archive fs.FS, name, dst string, modtime time.Time) (bool, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return false, nil
+	}
+	if modtime.IsZero() {
+		if info, err := fs.Stat(archive, name); err == nil {
+			modtime = info.ModTime()
+		}
+	}
+
+	err := imgproc.WriteAtomic(dst, func(tmp string) error {
+		src, err := archive.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		out, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(tmp, modtime, modtime)
+	})
+	return err == nil, err
+}
+
+// writeSidecar writes the sidecar of an imported source,
+// an existing or an empty sidecar isn't written.
+func writeSidecar(source string, sidecar gallery.Sidecar) error {
+	if sidecar.Title == "" && sidecar.Caption == "" && len(sidecar.Tags) == 0 && sidecar.Date == "" && sidecar.Rating == 0 {
+		return nil
+	}
+	path := gallery.ReplaceExt(source, ".yaml")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(sidecar)
+	if err != nil {
+		return err
+	}
+	return imgproc.WriteFile(path, data)
+}
+
+// writeGalleryConfig writes the gallery.yaml of an imported album,
+// an existing configuration is kept.
+func writeGalleryConfig(dir string, config ImportedGallery) error {
+	path := filepath.Join(dir, gallery.ConfigName)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return imgproc.WriteFile(path, data)
+}
+
+// writeOrder writes the order.txt of an imported album,
+// an existing order is kept.
+func writeOrder(dir string, names []string) error {
+	path := filepath.Join(dir, "order.txt")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	return imgproc.WriteFile(path, []byte(strings.Join(names, "\n")+"\n"))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var (
+	author  = BuildFlags.String("author", "Egon Elbre", "author of the photos")
+	license = BuildFlags.String("license", "", "url of the license of the photos")
+)
+
+// ImageObject is a schema.org ImageObject.
+type ImageObject struct {
+	Context      string  `json:"@context,omitempty"`
+	Type         string  `json:"@type"`
+	Name         string  `json:"name,omitempty"`
+	Caption      string  `json:"caption,omitempty"`
+	ContentURL   string  `json:"contentUrl"`
+	ThumbnailURL string  `json:"thumbnailUrl,omitempty"`
+	URL          string  `json:"url,omitempty"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	DateCreated  string  `json:"dateCreated,omitempty"`
+	Keywords     string  `json:"keywords,omitempty"`
+	Author       *Person `json:"author,omitempty"`
+	Creator      *Person `json:"creator,omitempty"`
+	License      string  `json:"license,omitempty"`
+}
+
+// ImageGallery is a schema.org ImageGallery.
+type ImageGallery struct {
+	Context     string         `json:"@context"`
+	Type        string         `json:"@type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url"`
+	DateCreated string         `json:"dateCreated,omitempty"`
+	Author      *Person        `json:"author,omitempty"`
+	License     string         `json:"license,omitempty"`
+	Images      []*ImageObject `json:"image,omitempty"`
+}
+
+// Person is a schema.org Person.
+type Person struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+func authorPerson() *Person {
+	if *author == "" {
+		return nil
+	}
+	return &Person{Type: "Person", Name: *author}
+}
+
+// NewImageObject describes the image as a schema.org ImageObject.
+func NewImageObject(image *gallery.Image) *ImageObject {
+	object := &ImageObject{
+		Type:         "ImageObject",
+		Name:         image.Title,
+		Caption:      image.Caption,
+		ContentURL:   AbsoluteURL(image.ImageLink()),
+		ThumbnailURL: AbsoluteURL(image.ThumbLink()),
+		URL:          AbsoluteURL(image.PageLink()),
+		Author:       authorPerson(),
+		Creator:      authorPerson(),
+		License:      *license,
+	}
+	if image.Kind == gallery.KindVideo {
+		object.Type = "VideoObject"
+	}
+	if n := len(image.Renditions); n > 0 {
+		object.Width = image.Renditions[n-1].Width
+		object.Height = image.Renditions[n-1].Height
+	}
+	if image.Metadata != nil && !image.Metadata.Taken.IsZero() {
+		object.DateCreated = image.Metadata.Taken.Format(time.RFC3339)
+	}
+	for i, tag := range image.Tags {
+		if i > 0 {
+			object.Keywords += ", "
+		}
+		object.Keywords += tag
+	}
+	return object
+}
+
+// ImageJSONLD returns the structured data for an image page.
+func ImageJSONLD(image *gallery.Image) template.JS {
+	object := NewImageObject(image)
+	object.Context = "https://schema.org"
+	return marshalJSONLD(object)
+}
+
+// GalleryJSONLD returns the structured data for a gallery page.
+func GalleryJSONLD(g *gallery.Gallery) template.JS {
+	data := &ImageGallery{
+		Context:     "https://schema.org",
+		Type:        "ImageGallery",
+		Name:        g.Title,
+		Description: PlainText(string(g.Description)),
+		URL:         AbsoluteURL(g.PageLink() + "/"),
+		Author:      authorPerson(),
+		License:     *license,
+	}
+	if !g.Date.IsZero() {
+		data.DateCreated = g.Date.Format("2006-01-02")
+	}
+	for _, image := range g.Images {
+		data.Images = append(data.Images, NewImageObject(image))
+	}
+	return marshalJSONLD(data)
+}
+
+func marshalJSONLD(v interface{}) template.JS {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return template.JS(data)
+}
@@ -0,0 +1,10 @@
+package main
+
+var lightbox = BuildFlags.Bool("lightbox", false, "open gallery images in an overlay instead of the image page")
+
+// LightboxDir is the static directory with the lightbox script and style,
+// it's taken from the default theme when missing.
+const LightboxDir = "lightbox"
+
+// LightboxEnabled returns whether gallery pages use the lightbox.
+func LightboxEnabled() bool { return *lightbox }
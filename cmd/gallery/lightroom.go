@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var sqlite3 = ImportFlags.String("sqlite3", "sqlite3", "path to sqlite3 for reading Lightroom catalogs")
+
+// lightroomCollection is the kind of the regular collections, smart
+// collections are defined by rules and aren't imported.
+const lightroomCollection = "com.adobe.ag.library.collection"
+
+// lightroomImage is a photo in a Lightroom collection.
+type lightroomImage struct {
+	Collection int64   `json:"collection"`
+	Position   float64 `json:"position"`
+	Image      int64   `json:"image"`
+	Path       string  `json:"path"`
+	Rating     float64 `json:"rating"`
+	Pick       float64 `json:"pick"`
+	// CaptureTime is formatted as 2006-01-02T15:04:05 with optional fractions.
+	CaptureTime string `json:"captureTime"`
+	Title       string `json:"title"`
+	Caption     string `json:"caption"`
+}
+
+// lightroomCollectionRow is a collection or a collection set.
+type lightroomCollectionRow struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Parent   int64  `json:"parent"`
+	Creation string `json:"creationId"`
+}
+
+// queryCatalog runs the query on the catalog with sqlite3 and decodes
+// the rows into result.
+func queryCatalog(catalog, query string, result interface{}) error {
+	cmd := exec.Command(*sqlite3, "-readonly", "-json", catalog, query)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("sqlite3 %v: %v: %s", catalog, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	// queries without rows don't output anything
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(output, result)
+}
+
+// catalogHasColumn returns whether the table of the catalog has the column,
+// the schema differs between Lightroom versions.
+func catalogHasColumn(catalog, table, column string) bool {
+	var columns []struct {
+		Name string `json:"name"`
+	}
+	query := fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table)
+	if err := queryCatalog(catalog, query, &columns); err != nil {
+		return false
+	}
+	for _, c := range columns {
+		if strings.EqualFold(c.Name, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportLightroom imports the collections of a Lightroom Classic catalog,
+// each collection becomes a gallery in the collection order and collection
+// sets become parent directories. The photos are hard linked, or copied
+// across file systems, and get sidecars with their title, caption,
+// keywords, rating and capture time. Rejected photos get the rating -1.
+// The galleries are imported as private, so they can be reviewed before
+// publishing.
+//
+// The catalog is read with sqlite3, close Lightroom before importing.
+// Metadata saved into XMP sidecars is read while building, so photo
+// folders edited in Lightroom don't need to be imported.
+func ImportLightroom(dest string, exports []string) error {
+	if len(exports) != 1 || !strings.EqualFold(filepath.Ext(exports[0]), ".lrcat") {
+		return fmt.Errorf("usage: import lightroom <catalog.lrcat>")
+	}
+	catalog := exports[0]
+	if _, err := os.Stat(catalog); err != nil {
+		return err
+	}
+
+	var collections []lightroomCollectionRow
+	err := queryCatalog(catalog, `
+		SELECT id_local AS id, name, coalesce(parent, 0) AS parent, creationId
+		FROM AgLibraryCollection`, &collections)
+	if err != nil {
+		return err
+	}
+	byID := map[int64]lightroomCollectionRow{}
+	for _, collection := range collections {
+		byID[collection.ID] = collection
+	}
+
+	title := "''"
+	if catalogHasColumn(catalog, "AgLibraryIPTC", "title") {
+		title = "iptc.title"
+	}
+	var images []lightroomImage
+	err = queryCatalog(catalog, `
+		SELECT ci.collection, coalesce(ci.positionInCollection, 0) AS position,
+			i.id_local AS image, coalesce(i.rating, 0) AS rating, coalesce(i.pick, 0) AS pick,
+			coalesce(i.captureTime, '') AS captureTime,
+			root.absolutePath || folder.pathFromRoot || file.baseName || '.' || file.extension AS path,
+			coalesce(`+title+`, '') AS title, coalesce(iptc.caption, '') AS caption
+		FROM AgLibraryCollectionImage ci
+		JOIN Adobe_images i ON i.id_local = ci.image
+		JOIN AgLibraryFile file ON file.id_local = i.rootFile
+		JOIN AgLibraryFolder folder ON folder.id_local = file.folder
+		JOIN AgLibraryRootFolder root ON root.id_local = folder.rootFolder
+		LEFT JOIN AgLibraryIPTC iptc ON iptc.image = i.id_local
+		ORDER BY ci.collection, position`, &images)
+	if err != nil {
+		return err
+	}
+
+	var keywordRows []struct {
+		Image int64  `json:"image"`
+		Name  string `json:"name"`
+	}
+	err = queryCatalog(catalog, `
+		SELECT ki.image, k.name
+		FROM AgLibraryKeywordImage ki
+		JOIN AgLibraryKeyword k ON k.id_local = ki.tag
+		WHERE k.name IS NOT NULL`, &keywordRows)
+	if err != nil {
+		return err
+	}
+	keywords := map[int64][]string{}
+	for _, row := range keywordRows {
+		keywords[row.Image] = append(keywords[row.Image], row.Name)
+	}
+
+	// collection sets become the parent directories
+	dirs := map[int64]string{}
+	used := map[string]map[string]bool{}
+	var dirOf func(id int64) string
+	dirOf = func(id int64) string {
+		if dir, ok := dirs[id]; ok {
+			return dir
+		}
+		collection, ok := byID[id]
+		if !ok {
+			return ""
+		}
+		dirs[id] = ""
+		parent := dirOf(collection.Parent)
+		if used[parent] == nil {
+			used[parent] = map[string]bool{}
+		}
+		dirs[id] = filepath.Join(parent, importDirName(collection.Name, used[parent]))
+		return dirs[id]
+	}
+	for _, collection := range collections {
+		dirOf(collection.ID)
+	}
+
+	grouped := map[int64][]lightroomImage{}
+	for _, image := range images {
+		grouped[image.Collection] = append(grouped[image.Collection], image)
+	}
+	var ids []int64
+	for id := range grouped {
+		if byID[id].Creation == lightroomCollection {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, k int) bool { return dirOf(ids[i]) < dirOf(ids[k]) })
+
+	for _, id := range ids {
+		collection := byID[id]
+		target := filepath.Join(dest, dirOf(id))
+
+		config := ImportedGallery{
+			Title:      collection.Name,
+			Visibility: gallery.VisibilityPrivate,
+		}
+
+		var order []string
+		var earliest time.Time
+		copied, skipped := 0, 0
+		taken := map[string]bool{}
+		for _, image := range grouped[id] {
+			name := filepath.Base(image.Path)
+			if taken[strings.ToLower(name)] {
+				slog.Warn("photo with the same name already in the collection", "collection", collection.Name, "file", image.Path)
+				continue
+			}
+			taken[strings.ToLower(name)] = true
+			order = append(order, name)
+
+			source := filepath.Join(target, name)
+			if _, err := os.Stat(source); err == nil {
+				skipped++
+				continue
+			}
+			if err := imgproc.LinkFile(image.Path, source); err != nil {
+				slog.Warn("photo not imported", "collection", collection.Name, "error", err)
+				continue
+			}
+			copied++
+
+			sidecar := gallery.Sidecar{
+				Title:   strings.TrimSpace(image.Title),
+				Caption: strings.TrimSpace(image.Caption),
+				Tags:    keywords[image.Image],
+				Rating:  int(image.Rating),
+			}
+			if image.Pick < 0 {
+				sidecar.Rating = -1
+			}
+			if len(image.CaptureTime) >= 19 {
+				if t, err := time.ParseInLocation("2006-01-02T15:04:05", image.CaptureTime[:19], time.Local); err == nil {
+					sidecar.Date = t.Format(gallery.SidecarDate)
+					if earliest.IsZero() || t.Before(earliest) {
+						earliest = t
+					}
+				}
+			}
+			if err := writeSidecar(source, sidecar); err != nil {
+				return err
+			}
+		}
+
+		if copied == 0 {
+			slog.Info("collection already imported", "folder", target, "skipped", skipped)
+			continue
+		}
+		if !earliest.IsZero() {
+			config.Date = earliest.Format("2006-01-02")
+		}
+		if err := writeGalleryConfig(target, config); err != nil {
+			return err
+		}
+		if err := writeOrder(target, order); err != nil {
+			return err
+		}
+		slog.Info("collection imported", "folder", target, "photos", copied, "skipped", skipped)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// LiveReloadPath is the server-sent events endpoint used for live reload.
+const LiveReloadPath = "/_livereload"
+
+// liveReloadScript reloads the page when the server sends an event.
+const liveReloadScript = `<script>
+new EventSource("` + LiveReloadPath + `").addEventListener("reload", function(){ location.reload(); });
+</script>`
+
+// Reloader notifies connected browsers about rebuilds.
+type Reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+// reloader is notified by Watch after every rebuild.
+var reloader = &Reloader{clients: map[chan struct{}]struct{}{}}
+
+// Notify asks all connected browsers to reload.
+func (reloader *Reloader) Notify() {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+	for client := range reloader.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams reload events to the browser.
+func (reloader *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	reloader.mu.Lock()
+	reloader.clients[client] = struct{}{}
+	reloader.mu.Unlock()
+	defer func() {
+		reloader.mu.Lock()
+		delete(reloader.clients, client)
+		reloader.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// InjectLiveReload adds the live reload script to the html page.
+func InjectLiveReload(page []byte) []byte {
+	end := bytes.LastIndex(page, []byte("</body>"))
+	if end < 0 {
+		return append(page, liveReloadScript...)
+	}
+	var result []byte
+	result = append(result, page[:end]...)
+	result = append(result, liveReloadScript...)
+	result = append(result, page[end:]...)
+	return result
+}
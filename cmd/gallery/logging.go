@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+var (
+	verbose   = CommonFlags.Bool("verbose", false, "log every processed file with its duration")
+	quiet     = CommonFlags.Bool("quiet", false, "log only warnings and errors")
+	logformat = CommonFlags.String("log-format", "text", "log format: text or json")
+)
+
+// SetupLogging configures the default logger from the flags.
+func SetupLogging() error {
+	if *verbose && *quiet {
+		return fmt.Errorf("-verbose and -quiet are mutually exclusive")
+	}
+
+	options := &slog.HandlerOptions{Level: slog.LevelInfo}
+	switch {
+	case *verbose:
+		options.Level = slog.LevelDebug
+	case *quiet:
+		options.Level = slog.LevelWarn
+	}
+
+	var handler slog.Handler
+	switch *logformat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("unknown log format %q", *logformat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
@@ -0,0 +1,594 @@
+// Command gallery generates a static photo gallery site.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+var sizes = gallery.SizeList{256, 1024}
+
+func init() {
+	BuildFlags.Var(&sizes, "sizes", "comma separated rendition sizes, at least two, smallest is used for thumbnails and largest for image pages")
+}
+
+var T *template.Template
+var pagesonly = BuildFlags.Bool("pages", false, "generate only pages")
+var regenerate = BuildFlags.Bool("regenerate", false, "generate only pages")
+var genwebp = BuildFlags.Bool("webp", false, "generate WebP renditions in addition to JPEG and PNG")
+var largeformat = BuildFlags.String("large-format", "jpg", "large image format (jpg, png, webp, avif)")
+var thumbformat = BuildFlags.String("thumb-format", "auto", "thumbnail format (auto, jpg, png, webp, avif), auto uses PNG for lossless sources and JPEG otherwise")
+var losslessformat = BuildFlags.String("lossless-format", "png", "large image format for PNG sources (png, webp-lossless), empty uses -large-format")
+var sortorder = SourceFlags.String("sort", "date-desc", "image order: date-desc, date-asc, name-asc, name-desc, mtime or manual")
+var gallerysort = BuildFlags.String("gallery-sort", "name", "order of galleries on the index and parent pages: name, newest or weight from the gallery configuration")
+var organize = SourceFlags.String("organize", "directory", "how images are grouped into galleries: directory or date")
+var perpage = BuildFlags.Int("per-page", 0, "number of images on a gallery page, 0 disables pagination")
+var slugs = SourceFlags.Bool("slugs", true, "publish galleries and images under lowercase ASCII names, the names are displayed as is")
+var slugseparator = SourceFlags.String("slug-separator", "-", "separator replacing spaces and punctuation in slugs")
+var ignore = SourceFlags.String("ignore", "", "comma separated file and directory name patterns skipped in addition to hidden files, Thumbs.db and @eaDir")
+var minrating = SourceFlags.Int("min-rating", -1, "minimum XMP or EXIF star rating (0-5) of the published images, galleries can override it with min-rating; 0 leaves out rejected photos, -1 publishes all")
+var drafts = SourceFlags.Bool("drafts", false, "publish the draft galleries, configured with draft: true or in directories starting with _")
+var unlistedsecret = SourceFlags.String("unlisted-secret", ".unlisted-secret", "file with the secret the paths of unlisted galleries are derived from, created when missing")
+var followsymlinks = SourceFlags.Bool("follow-symlinks", false, "scan symlinked gallery directories, links that would form a cycle are skipped")
+var jobs = SourceFlags.Int("jobs", 0, "number of images processed in parallel, 0 uses all CPUs")
+
+func main() {
+	// flags before the command are checked once the command is known
+	all := MergeFlags("gallery", FlagGroups...)
+	all.Usage = Usage
+	all.Parse(os.Args[1:])
+
+	name := "build"
+	args := all.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	command := FindCommand(name)
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		Usage()
+		os.Exit(ExitUsage)
+	}
+
+	flags := command.FlagSet()
+	explicit := map[string]bool{}
+	all.Visit(func(f *flag.Flag) {
+		if flags.Lookup(f.Name) == nil {
+			configError(fmt.Errorf("flag -%v is not used by %v", f.Name, command.Name))
+		}
+		explicit[f.Name] = true
+	})
+	flags.Parse(args)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if err := ApplySiteConfig(explicit); err != nil {
+		configError(err)
+	}
+	if err := SetupLogging(); err != nil {
+		configError(err)
+	}
+	if err := ValidateFlags(); err != nil {
+		configError(err)
+	}
+	imgproc.SVGHeight = sizes.Large()
+	gallery.Jobs = *jobs
+	gallery.Slugs = *slugs
+	gallery.SlugSeparator = *slugseparator
+	gallery.FollowSymlinks = *followsymlinks
+	gallery.UnlistedSecretFile = *unlistedsecret
+	gallery.Drafts = *drafts
+	gallery.MinRating = *minrating
+	for _, pattern := range strings.Split(*ignore, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			gallery.Ignore = append(gallery.Ignore, pattern)
+		}
+	}
+	if err := LoadHooks(); err != nil {
+		configError(err)
+	}
+	if err := OpenSite(); err != nil {
+		configError(err)
+	}
+
+	if IsRemote(*sourcedir) {
+		if !command.Source {
+			configError(fmt.Errorf("%v needs a local -source directory", name))
+		}
+		if *watch {
+			configError(fmt.Errorf("-watch needs a local -source directory"))
+		}
+		files, err := OpenSource(*sourcedir)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(ExitFailure)
+		}
+		gallery.Files = files
+		*sourcedir = files.Dir
+	}
+
+	if !*headless {
+		var err error
+		if T, err = LoadTemplates(); err != nil {
+			configError(err)
+		}
+	}
+
+	if err := command.Run(flags.Args()); err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitFailure)
+	}
+}
+
+// Exit codes of the gallery command.
+const (
+	ExitFailure = 1 // some files or steps failed
+	ExitUsage   = 2 // invalid command, flags, configuration or templates
+)
+
+// configError reports an error in the configuration and exits.
+func configError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(ExitUsage)
+}
+
+// Workers returns the number of parallel workers.
+func Workers() int {
+	if *jobs > 0 {
+		return *jobs
+	}
+	return runtime.GOMAXPROCS(-1)
+}
+
+// ValidateFlags checks the flags that have a limited set of values.
+func ValidateFlags() error {
+	if err := gallery.ValidSortOrder(*sortorder); err != nil {
+		return err
+	}
+	if err := gallery.ValidGalleryOrder(*gallerysort); err != nil {
+		return err
+	}
+	if err := gallery.ValidOrganize(*organize); err != nil {
+		return err
+	}
+	switch *slugseparator {
+	case "-", "_", "":
+	default:
+		return fmt.Errorf("invalid slug separator %q, expected -, _ or empty", *slugseparator)
+	}
+	for _, pattern := range strings.Split(*ignore, ",") {
+		if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	if err := ValidOnly(); err != nil {
+		return err
+	}
+	if *admin && os.Getenv(AdminPasswordEnv) == "" {
+		return fmt.Errorf("%v must be set for -admin", AdminPasswordEnv)
+	}
+	if *minrating < -1 || *minrating > 5 {
+		return fmt.Errorf("invalid minimum rating %d, expected -1 to 5", *minrating)
+	}
+	if *stackdistance < 0 || *stackdistance > 64 {
+		return fmt.Errorf("invalid stack distance %d, expected 0-64", *stackdistance)
+	}
+	if *jobs < 0 {
+		return fmt.Errorf("invalid number of jobs %d", *jobs)
+	}
+	if err := ValidZip(*ziparchives); err != nil {
+		return err
+	}
+	if *sharpen < 0 || *sharpenradius < 0 {
+		return fmt.Errorf("invalid sharpening amount %g or radius %g", *sharpen, *sharpenradius)
+	}
+	if _, ok := imgproc.Filters[*filter]; !ok {
+		return fmt.Errorf("unknown filter %q, expected nearest, bilinear, catmullrom or lanczos", *filter)
+	}
+	var err error
+	if processor, err = imgproc.FindProcessor(*processorname); err != nil {
+		return err
+	}
+	for _, format := range []string{*largeformat, *thumbformat, *losslessformat} {
+		if format != "" && format != "auto" && imgproc.FormatExt(format) == "" {
+			return fmt.Errorf("unknown output format %q", format)
+		}
+	}
+	return nil
+}
+
+// Build generates the site, with pagesOnly the images are not processed.
+func Build(pagesOnly bool) error { return BuildChanged(pagesOnly, nil) }
+
+// BuildChanged generates the site regenerating only the galleries affected
+// by the changed source paths: the galleries containing them and their
+// parents. The site-wide pages are always generated, nil changes
+// regenerate every gallery.
+func BuildChanged(pagesOnly bool, changed []string) error {
+	rebuild := func(g *gallery.Gallery) bool {
+		return Selected(g) && Affected(g, changed)
+	}
+
+	ResetOutputs()
+	failures.Reset()
+	progress = nil
+	if err := ListSite(); err != nil {
+		return err
+	}
+
+	manifestPath := Output(ManifestName)
+	MarkOutput(manifestPath)
+	if loaded, err := LoadManifest(manifestPath); err != nil {
+		failures.Add("loading manifest", manifestPath, err)
+	} else {
+		manifest = loaded
+	}
+
+	// static files are published first, pages link to the fingerprinted names
+	if !*headless {
+		if err := CopyStatic(); err != nil {
+			failures.Add("copying static files", "", err)
+		}
+	}
+
+	galleries, unpublished, err := gallery.Load(*sourcedir, *organize)
+	if err != nil {
+		return err
+	}
+	if !*drafts {
+		skipped := 0
+		for _, g := range unpublished {
+			if g.Draft() {
+				skipped++
+			}
+		}
+		if skipped > 0 {
+			slog.Info("draft galleries skipped, use -drafts to include them", "count", skipped)
+		}
+	}
+
+	for _, g := range galleries {
+		for _, problem := range gallery.Prepare(g, *sortorder) {
+			slog.Warn(problem.Error(), "gallery", g.Name)
+		}
+	}
+	if err := gallery.RunAfterScan(galleries); err != nil {
+		failures.Add("after-scan hook", "", err)
+	}
+
+	// update paths
+	var queue []imageJob
+	for _, g := range galleries {
+		for _, image := range g.Images {
+			AssignPaths(image)
+			if g.PublishesOriginals(*originals) {
+				image.Original = filepath.Join("originals", image.Unbound)
+			}
+			image.Preset = g.Preset()
+			if rebuild(g) {
+				queue = append(queue, imageJob{g, image})
+			}
+		}
+	}
+
+	// generate images, all galleries share the workers
+	if !pagesOnly {
+		progress = NewProgress(len(queue))
+		async.Iter(len(queue), Workers(), func(i int) {
+			job := queue[i]
+			start := time.Now()
+			skipped, err := job.Process()
+			progress.Image(job.Image, skipped, time.Since(start), err)
+		})
+		progress.ImagesDone()
+	}
+
+	for _, g := range galleries {
+		for _, image := range g.Images {
+			for _, rendition := range image.Renditions {
+				rendition.UpdateSize(func(path string) (int, int) { return OutputSize(Output(path)) })
+			}
+			UpdatePlaceholders(image)
+		}
+
+		if !rebuild(g) {
+			continue
+		}
+		CreateStacks(g)
+		if *headless {
+			continue
+		}
+
+		// generate pages
+		for i, image := range g.Images {
+			var prev, next string
+			if i > 0 {
+				prev = g.Images[i-1].PageLink()
+			}
+			if i+1 < len(g.Images) {
+				next = g.Images[i+1].PageLink()
+			}
+
+			page := "image.html"
+			if image.Kind == gallery.KindVideo {
+				page = "video.html"
+			}
+
+			CreatePage(gallery.ReplaceExt(image.Unbound, ".html"), page, map[string]interface{}{
+				"Title":   image.Title,
+				"Gallery": g,
+				"Back":    g.ImagePage(image, *perpage),
+				"Image":   image,
+				"Prev":    prev,
+				"Next":    next,
+				"Social":  ImageSocial(g, image),
+				"JSONLD":  ImageJSONLD(image),
+			})
+		}
+	}
+
+	for _, g := range galleries {
+		gallery.SortGalleries(g.Children, *gallerysort)
+	}
+
+	var roots []*gallery.Gallery
+	for _, g := range galleries {
+		if g.Cover == nil {
+			g.Cover = g.ChildCover()
+		}
+		if g.Parent == nil && !g.Unlisted() {
+			roots = append(roots, g)
+		}
+		if g.Config.Visibility == gallery.VisibilityUnlisted {
+			slog.Info("unlisted gallery", "gallery", g.Path, "link", AbsoluteURL(g.PageLink()+"/"))
+		}
+		if !rebuild(g) {
+			continue
+		}
+
+		hasZip := false
+		if *ziparchives != "" && len(g.Images) > 0 {
+			if err := CreateZip(g, *ziparchives); err != nil {
+				failures.Add("zip", g.ZipFile(), err)
+			} else {
+				hasZip = true
+			}
+		}
+
+		hasWaypoints := false
+		if WaypointsEnabled() {
+			var err error
+			hasWaypoints, err = CreateWaypoints(g)
+			if err != nil {
+				failures.Add("waypoints", g.Unbound, err)
+			}
+		}
+
+		if JSONEnabled() {
+			CreateGalleryJSON(g)
+		}
+		if *headless {
+			continue
+		}
+
+		for _, page := range g.Paginate(*perpage) {
+			CreatePage(g.PageNumberFile(page.Number), "gallery.html", map[string]interface{}{
+				"Title":     g.Title,
+				"Gallery":   g,
+				"Images":    page.Images,
+				"Page":      page,
+				"Waypoints": hasWaypoints,
+				"Zip":       hasZip,
+				"Social":    GallerySocial(g),
+				"JSONLD":    GalleryJSONLD(g),
+			})
+		}
+	}
+
+	gallery.SortGalleries(roots, *gallerysort)
+
+	if OnlyPatterns() != nil {
+		if *clean {
+			slog.Warn("clean skipped: only some galleries are built with -only")
+		}
+		return finishBuild(galleries, pagesOnly, manifestPath)
+	}
+
+	if JSONEnabled() {
+		CreateIndexJSON(roots)
+	}
+	if !*headless {
+		CreateSitePages(galleries, roots)
+	}
+
+	if *clean {
+		if pagesOnly {
+			slog.Warn("clean skipped: images are not processed with -pages")
+		} else if changed != nil {
+			slog.Debug("clean skipped: only the changed galleries are built")
+		} else if err := Clean(*outputdir, *dryrun); err != nil {
+			failures.Add("clean", "", err)
+		}
+	}
+
+	return finishBuild(galleries, pagesOnly, manifestPath)
+}
+
+// Affected returns whether the changed source paths are in the gallery
+// directory or in a nested gallery, nil changes affect every gallery.
+// Galleries regrouped by date are always affected.
+func Affected(g *gallery.Gallery, changed []string) bool {
+	if changed == nil || *organize != "directory" {
+		return true
+	}
+	source := filepath.Clean(*sourcedir)
+	for _, path := range changed {
+		// the top-level gallery only contains the images next to the galleries
+		if path == g.Path || (g.Path != source && isUnder(path, g.Path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSitePages writes the pages and files describing the whole site,
+// unlisted galleries are left out of them.
+func CreateSitePages(galleries map[string]*gallery.Gallery, roots []*gallery.Gallery) {
+	galleries = gallery.Listed(galleries)
+	if tags := gallery.CollectTags(galleries, *sortorder); len(tags) > 0 {
+		CreateTagPages(tags)
+	}
+
+	if err := CreateTimeline(galleries); err != nil {
+		failures.Add("timeline", "", err)
+	}
+	if err := CreateMap(galleries); err != nil {
+		failures.Add("map", "", err)
+	}
+	CreateFeeds(galleries)
+	CreateSitemap(galleries)
+	if *pwa {
+		if err := CreatePWA(galleries); err != nil {
+			failures.Add("web app manifest", "", err)
+		}
+	}
+	if err := CreateRobots(); err != nil {
+		failures.Add("robots", "robots.txt", err)
+	}
+
+	CreatePage("index.html", "index.html", map[string]interface{}{
+		"Title":     "Galleries",
+		"Galleries": roots,
+		"Map":       MapEnabled(),
+		"Social": &Social{
+			Type:  "website",
+			Title: "Galleries",
+			URL:   AbsoluteURL("/"),
+		},
+	})
+
+	CreatePage("404.html", "404.html", map[string]interface{}{
+		"Title": "Not Found",
+	})
+}
+
+// finishBuild runs the after-build hooks and saves the manifest.
+func finishBuild(galleries map[string]*gallery.Gallery, pagesOnly bool, manifestPath string) error {
+	progress.Done()
+
+	if err := gallery.RunAfterBuild(galleries, *outputdir); err != nil {
+		failures.Add("after-build hook", "", err)
+	}
+
+	if !pagesOnly {
+		if err := manifest.Save(manifestPath); err != nil {
+			failures.Add("saving manifest", manifestPath, err)
+		}
+	}
+
+	return failures.Err()
+}
+
+// imageJob is an image waiting to be processed.
+type imageJob struct {
+	Gallery *gallery.Gallery
+	Image   *gallery.Image
+}
+
+// Process generates the renditions of the image and publishes the original,
+// skipped reports whether everything was up to date.
+func (job imageJob) Process() (skipped bool, err error) {
+	g, image := job.Gallery, job.Image
+	if err := gallery.RunBeforeImage(g, image); err != nil {
+		return false, err
+	}
+
+	var errs Errors
+	skipped, err = ProcessImage(image)
+	errs.Add(err)
+	if image.Original != "" {
+		errs.Add(PublishOriginal(image))
+	}
+	errs.Add(gallery.RunAfterImage(g, image))
+	return skipped, errs.Err()
+}
+
+func FileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func CreatePage(name string, template string, data map[string]interface{}) {
+	if err := gallery.RunBeforePage(name, template, data); err != nil {
+		failures.Add("before-page hook", name, err)
+		return
+	}
+	name = Output(name)
+	progress.Page()
+	start := time.Now()
+
+	var buffer bytes.Buffer
+	if err := T.ExecuteTemplate(&buffer, template, data); err != nil {
+		failures.Add("template "+template, name, err)
+		return
+	}
+	if err := WriteOutput(name, buffer.Bytes()); err != nil {
+		failures.Add("page", name, err)
+		return
+	}
+	slog.Debug("rendered", "page", name, "template", template, "duration", time.Since(start))
+}
+
+func CopyDir(src string, dst string) (err error) {
+	srcinfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dst, srcinfo.Mode())
+	if err != nil {
+		return err
+	}
+
+	dir, _ := os.Open(src)
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		srcname := filepath.Join(src, info.Name())
+		dstname := filepath.Join(dst, info.Name())
+
+		if info.IsDir() {
+			err = CopyDir(srcname, dstname)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = CopyFile(srcname, dstname)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return
+}
+
+func CopyFile(src, dst string) (err error) {
+	return WriteOutputFile(dst, func(file string) error {
+		return imgproc.CopyFile(src, file)
+	})
+}
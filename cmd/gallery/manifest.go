@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+// ManifestName is the name of the build manifest in the output directory.
+const ManifestName = ".manifest.json"
+
+// processingFlags are the settings that affect how outputs are created.
+var processingFlags = []string{
+	"sizes", "large-format", "thumb-format", "lossless-format",
+	"webp", "webp-quality", "jpeg-quality", "jpeg-quality-sizes",
+	"avif-quality", "avif-speed", "progressive",
+	"thumb-crop", "thumb-aspect", "size-modes", "filter", "size-filters",
+	"sharpen", "sharpen-radius", "sharpen-sizes", "face-detector",
+	"keep-metadata", "gps-privacy", "dcraw", "processor",
+	"transcode", "video-previews",
+}
+
+// Manifest records from which source and with which settings
+// every output was created.
+type Manifest struct {
+	mu      sync.Mutex
+	Sources map[string]SourceState `json:"sources"`
+	Outputs map[string]OutputState `json:"outputs"`
+}
+
+// SourceState is the content hash of a source file,
+// the hash is reused while size and modification time stay the same.
+type SourceState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+// OutputState describes what an output was created from.
+type OutputState struct {
+	Source   string `json:"source"`
+	Hash     string `json:"hash"`
+	Settings string `json:"settings"`
+}
+
+// manifest is the manifest of the current build.
+var manifest = NewManifest()
+
+func NewManifest() *Manifest {
+	return &Manifest{
+		Sources: map[string]SourceState{},
+		Outputs: map[string]OutputState{},
+	}
+}
+
+// LoadManifest loads the manifest from path in the site,
+// a missing manifest results in an empty one.
+func LoadManifest(path string) (*Manifest, error) {
+	m := NewManifest()
+	if !OutputExists(path) {
+		return m, nil
+	}
+	data, err := ReadOutput(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return NewManifest(), fmt.Errorf("%v: %v", path, err)
+	}
+	if m.Sources == nil {
+		m.Sources = map[string]SourceState{}
+	}
+	if m.Outputs == nil {
+		m.Outputs = map[string]OutputState{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest to path.
+func (m *Manifest) Save(path string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "\t")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return WriteOutput(path, data)
+}
+
+// SourceHash returns the content hash of the source file,
+// a remote source file is fetched only when it has changed.
+func (m *Manifest) SourceHash(path string) string {
+	stat, err := gallery.Files.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	m.mu.Lock()
+	state, ok := m.Sources[path]
+	m.mu.Unlock()
+	// a remote file without a known size and time is checked by fetching it
+	known := stat.Size() >= 0 && !stat.ModTime().IsZero()
+	if ok && known && state.Size == stat.Size() && state.ModTime.Equal(stat.ModTime()) {
+		return state.Hash
+	}
+
+	if err := gallery.Files.Fetch(path); err != nil {
+		return ""
+	}
+	if !known {
+		if stat, err = gallery.Files.Stat(path); err != nil {
+			return ""
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return ""
+	}
+
+	state = SourceState{
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+		Hash:    fmt.Sprintf("%x", hash.Sum(nil)),
+	}
+	m.mu.Lock()
+	m.Sources[path] = state
+	m.mu.Unlock()
+	return state.Hash
+}
+
+// Fresh returns whether output exists and was created from the current
+// content of source with the same settings. A stale output is restored
+// from the cache when it has a matching copy.
+//
+// The output is marked as part of the build.
+func (m *Manifest) Fresh(output, source, settings string) bool {
+	MarkOutput(output)
+	if *regenerate {
+		return false
+	}
+
+	if OutputExists(output) {
+		m.mu.Lock()
+		state, ok := m.Outputs[output]
+		m.mu.Unlock()
+		if ok && state.Source == source && state.Settings == settings && state.Hash == m.SourceHash(source) {
+			return true
+		}
+	}
+
+	if !RestoreCache(output, m.SourceHash(source), settings) {
+		return false
+	}
+	m.record(output, source, settings)
+	return true
+}
+
+// Record notes that output was created from source with settings
+// and adds it to the cache.
+func (m *Manifest) Record(output, source, settings string) {
+	m.record(output, source, settings)
+	StoreCache(output, m.SourceHash(source), settings)
+}
+
+// record notes that output was created from source with settings.
+func (m *Manifest) record(output, source, settings string) {
+	hash := m.SourceHash(source)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Outputs[output] = OutputState{
+		Source:   source,
+		Hash:     hash,
+		Settings: settings,
+	}
+}
+
+// Forget removes the record of output.
+func (m *Manifest) Forget(output string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Outputs, output)
+}
+
+// Settings fingerprints the processing flags together with
+// the output specific values.
+func Settings(values ...interface{}) string {
+	hash := sha256.New()
+	for _, name := range processingFlags {
+		if f := LookupFlag(name); f != nil {
+			fmt.Fprintf(hash, "%s=%s\n", name, f.Value.String())
+		}
+	}
+	fmt.Fprintln(hash, values...)
+	return fmt.Sprintf("%x", hash.Sum(nil)[:16])
+}
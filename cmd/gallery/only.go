@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/egonelbre/gallery"
+)
+
+var only = BuildFlags.String("only", "", "comma separated paths or patterns of the galleries to build, e.g. 2024/*; other galleries and the site-wide pages are left as they are")
+
+// OnlyPatterns returns the -only patterns, nil builds every gallery.
+func OnlyPatterns() []string {
+	var patterns []string
+	for _, pattern := range strings.Split(*only, ",") {
+		pattern = strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+		if pattern != "" {
+			patterns = append(patterns, strings.ToLower(pattern))
+		}
+	}
+	return patterns
+}
+
+// ValidOnly checks the syntax of the -only patterns.
+func ValidOnly() error {
+	for _, pattern := range OnlyPatterns() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid -only pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Selected returns whether the gallery is built. A gallery is selected
+// when its published or source path, or the path of one of its parents,
+// matches an -only pattern.
+func Selected(g *gallery.Gallery) bool {
+	patterns := OnlyPatterns()
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for ; g != nil; g = g.Parent {
+		names := []string{strings.ToLower(filepath.ToSlash(g.Unbound))}
+		if rel, err := filepath.Rel(*sourcedir, g.Path); err == nil {
+			names = append(names, strings.ToLower(filepath.ToSlash(rel)))
+		}
+		for _, pattern := range patterns {
+			for _, name := range names {
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
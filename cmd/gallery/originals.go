@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/egonelbre/gallery"
+)
+
+var originals = BuildFlags.Bool("originals", false, "publish the unmodified source files, including their metadata, under originals/; galleries can override it with the originals setting")
+
+// PublishOriginal copies the source file of the image to the originals,
+// unless an up to date copy already exists.
+func PublishOriginal(image *gallery.Image) error {
+	target := Output(image.Original)
+	settings := Settings("original")
+	if manifest.Fresh(target, image.Raw, settings) {
+		return nil
+	}
+
+	if err := copySource(image, target); err != nil {
+		return err
+	}
+	manifest.Record(target, image.Raw, settings)
+	return nil
+}
+
+// copySource publishes the source file of the image unmodified at target.
+func copySource(image *gallery.Image, target string) error {
+	if err := gallery.Files.Fetch(image.Raw); err != nil {
+		return err
+	}
+	return CopyFile(image.Raw, target)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// PDF is a minimal PDF writer for pages of JPEG images and text.
+type PDF struct {
+	// objects are numbered from 1, the catalog, page tree and
+	// font are reserved as 1, 2 and 3
+	objects [][]byte
+	pages   []int
+
+	open     bool
+	content  bytes.Buffer
+	xobjects []string
+}
+
+// NewPDF creates an empty document.
+func NewPDF() *PDF {
+	pdf := &PDF{objects: make([][]byte, 3)}
+	pdf.objects[2] = []byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	return pdf
+}
+
+// add adds an object and returns its number.
+func (pdf *PDF) add(object []byte) int {
+	pdf.objects = append(pdf.objects, object)
+	return len(pdf.objects)
+}
+
+// stream formats a stream object.
+func stream(dict string, data []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+// NewPage finishes the current page and starts a new one,
+// the origin is at the bottom left corner.
+func (pdf *PDF) NewPage() {
+	pdf.finishPage()
+	pdf.open = true
+}
+
+// JPEG draws the baseline RGB JPEG data of size w×h into the rectangle.
+func (pdf *PDF) JPEG(data []byte, w, h int, x, y, width, height float64) {
+	id := pdf.add(stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", w, h), data))
+	pdf.xobjects = append(pdf.xobjects, fmt.Sprintf("/Im%d %d 0 R", id, id))
+	fmt.Fprintf(&pdf.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, x, y, id)
+}
+
+// Text draws a line of text in Helvetica with the baseline at y.
+func (pdf *PDF) Text(x, y, size float64, text string) {
+	fmt.Fprintf(&pdf.content, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", size, x, y, pdfString(text))
+}
+
+// pdfString escapes text for a string literal, characters
+// outside of Latin-1 are replaced with "?".
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0xFF:
+			b.WriteByte('?')
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "\\%03o", r)
+		}
+	}
+	return b.String()
+}
+
+// finishPage adds the current page to the document.
+func (pdf *PDF) finishPage() {
+	if !pdf.open {
+		return
+	}
+	contents := pdf.add(stream("", pdf.content.Bytes()))
+	page := pdf.add([]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Contents %d 0 R /Resources << /Font << /F1 3 0 R >> /XObject << %s >> >> >>",
+		PageWidth, PageHeight, contents, strings.Join(pdf.xobjects, " "))))
+	pdf.pages = append(pdf.pages, page)
+
+	pdf.open = false
+	pdf.content.Reset()
+	pdf.xobjects = nil
+}
+
+// PageCount returns the number of pages.
+func (pdf *PDF) PageCount() int {
+	if pdf.open {
+		return len(pdf.pages) + 1
+	}
+	return len(pdf.pages)
+}
+
+// Bytes finishes the document and returns its encoding.
+func (pdf *PDF) Bytes() []byte {
+	pdf.finishPage()
+
+	var kids []string
+	for _, page := range pdf.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	pdf.objects[0] = []byte("<< /Type /Catalog /Pages 2 0 R >>")
+	pdf.objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(pdf.objects))
+	for i, object := range pdf.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(object)
+		b.WriteString("\nendobj\n")
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(pdf.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pdf.objects)+1, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"html/template"
+	"image"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var blurhash = BuildFlags.Bool("blurhash", false, "compute BlurHash placeholders for images")
+var lqip = BuildFlags.Bool("lqip", false, "inline tiny base64 previews of images")
+
+// SetPlaceholders computes the loading placeholders from the thumbnail.
+func SetPlaceholders(image *gallery.Image, thumb image.Image) {
+	if *blurhash {
+		image.BlurHash = imgproc.BlurHash(thumb, 4, 3)
+	}
+	if *lqip {
+		image.LQIP = template.URL(imgproc.LQIP(thumb))
+	}
+}
+
+// UpdatePlaceholders computes the placeholders from the published thumbnail,
+// when they were not computed during processing.
+func UpdatePlaceholders(image *gallery.Image) {
+	missing := (*blurhash && image.BlurHash == "") || (*lqip && image.LQIP == "")
+	if !missing {
+		return
+	}
+
+	thumb, err := DecodeOutput(Output(image.Thumb))
+	if err != nil {
+		// the thumbnail format may not be decodable, use the source instead
+		if err = gallery.Files.Fetch(image.Raw); err == nil {
+			thumb, err = imgproc.Load(image.Raw)
+		}
+		if err != nil {
+			failures.Add("placeholder", image.Raw, err)
+			return
+		}
+		thumb = Thumbnail(imgproc.ApplyPreset(thumb, image.Preset))
+	}
+	SetPlaceholders(image, thumb)
+}
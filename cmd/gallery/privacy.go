@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+var (
+	keepmetadata = BuildFlags.String("keep-metadata", "", "comma separated EXIF fields to keep in published JPEGs, e.g. Make,Model,DateTimeOriginal; by default all metadata is stripped")
+	gpsprivacy   = BuildFlags.Bool("gps-privacy", true, "never publish GPS fields, even when listed in -keep-metadata")
+)
+
+// KeptFields returns the EXIF fields that should be kept in published files.
+func KeptFields() []exif.FieldName {
+	var fields []exif.FieldName
+	for _, name := range strings.Split(*keepmetadata, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if *gpsprivacy && strings.HasPrefix(name, "GPS") {
+			continue
+		}
+		switch exif.FieldName(name) {
+		case exif.Orientation, exif.MakerNote,
+			exif.ExifIFDPointer, exif.GPSInfoIFDPointer, exif.InteroperabilityIFDPointer:
+			// published images are upright and maker notes contain offsets
+			continue
+		}
+		fields = append(fields, exif.FieldName(name))
+	}
+	return fields
+}
+
+// StripsMetadata returns whether published files should not contain any metadata.
+func StripsMetadata() bool { return len(KeptFields()) == 0 }
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var thumbaspect imgproc.Aspect
+var thumbcrop = BuildFlags.String("thumb-crop", "smart", "crop strategy for -thumb-aspect and the fill size mode (smart, face, center)")
+
+func init() {
+	BuildFlags.Var(&thumbaspect, "thumb-aspect", "crop thumbnails to a fixed aspect, e.g. 1:1 for square tiles")
+}
+
+// Thumbnail creates the thumbnail for m.
+func Thumbnail(m image.Image) image.Image {
+	return Resize(m, sizes.Thumb())
+}
+
+// Resize scales m down to size using the mode of the size.
+func Resize(m image.Image, size int) image.Image {
+	mode := ModeFor(size)
+	switch mode.Mode {
+	case "fill":
+		m = imgproc.Crop(m, float64(mode.Aspect), *thumbcrop)
+	case "pad":
+		m = imgproc.Pad(m, float64(mode.Aspect), color.Black)
+	}
+	return processor.Downscale(m, size, FilterFor(size))
+}
+
+// ProcessImage generates all the published renditions of image,
+// skipped reports whether they were all up to date.
+func ProcessImage(image *gallery.Image) (skipped bool, err error) {
+	switch image.Kind {
+	case gallery.KindAnimation, gallery.KindVector:
+		return processOriginal(image)
+	case gallery.KindVideo:
+		return processVideo(image)
+	default:
+		return processPhoto(image)
+	}
+}
+
+// AssignPaths assigns the output paths of all renditions.
+func AssignPaths(image *gallery.Image) {
+	image.ThumbFormat = ThumbFormat(image)
+	image.Thumb = filepath.Join(*thumbsdir, gallery.ReplaceExt(image.Unbound, imgproc.FormatExt(image.ThumbFormat)))
+	switch image.Kind {
+	case gallery.KindPhoto:
+		image.Format = *largeformat
+		if *losslessformat != "" && strings.EqualFold(filepath.Ext(image.Raw), ".png") {
+			image.Format = *losslessformat
+		}
+		image.Path = gallery.ReplaceExt(image.Path, imgproc.FormatExt(image.Format))
+	case gallery.KindVideo:
+		image.Poster = gallery.ReplaceExt(image.Path, ".poster"+imgproc.FormatExt(*largeformat))
+		if *transcode {
+			image.Path = gallery.ReplaceExt(image.Path, ".mp4")
+		}
+		if *previews {
+			image.Preview = gallery.ReplaceExt(image.Thumb, ".preview.webp")
+		}
+	}
+	if *genwebp {
+		hasLarge := image.Kind == gallery.KindPhoto || image.Kind == gallery.KindAnimation
+		if hasLarge && filepath.Ext(image.Path) != ".webp" {
+			image.WebP = gallery.ReplaceExt(image.Path, ".webp")
+		}
+		if filepath.Ext(image.Thumb) != ".webp" {
+			image.ThumbWebP = gallery.ReplaceExt(image.Thumb, ".webp")
+		}
+	}
+	if *hashnames {
+		HashNames(image)
+	}
+
+	if image.Kind != gallery.KindPhoto {
+		return
+	}
+
+	image.Renditions = nil
+	for i, size := range sizes {
+		rendition := &gallery.Rendition{
+			Size:        size,
+			Format:      image.Format,
+			Quality:     JPEGQuality(size),
+			Progressive: *progressive && i > 0,
+		}
+		switch {
+		case i == 0:
+			rendition.Format = image.ThumbFormat
+			rendition.Path = image.Thumb
+			rendition.WebP = image.ThumbWebP
+		case i == len(sizes)-1:
+			rendition.Path = image.Path
+			rendition.WebP = image.WebP
+		default:
+			suffix := "." + strconv.Itoa(size)
+			rendition.Path = gallery.ReplaceExt(image.Path, suffix+imgproc.FormatExt(image.Format))
+			if image.WebP != "" {
+				rendition.WebP = gallery.ReplaceExt(image.Path, suffix+".webp")
+			}
+		}
+		image.Renditions = append(image.Renditions, rendition)
+	}
+}
+
+// ThumbFormat returns the thumbnail format for image,
+// resolving "auto" based on whether the source is photographic.
+func ThumbFormat(image *gallery.Image) string {
+	if *thumbformat != "auto" {
+		return *thumbformat
+	}
+	switch strings.ToLower(filepath.Ext(image.Raw)) {
+	case ".png", ".gif", ".svg":
+		return "png"
+	}
+	return "jpg"
+}
+
+func processPhoto(image *gallery.Image) (skipped bool, err error) {
+	var errs Errors
+	stale := false
+
+	// keep the WebP alternatives lossless when the main renditions are
+	webpformat := "webp"
+	if imgproc.IsLossless(image.Format) {
+		webpformat = "webp-lossless"
+	}
+
+	settings := func(rendition *gallery.Rendition) string {
+		return Settings(rendition.Size, rendition.Format, rendition.Quality, rendition.Progressive, image.Preset)
+	}
+	webpsettings := func(rendition *gallery.Rendition) string {
+		return Settings(rendition.Size, webpformat, image.Preset)
+	}
+
+	done := true
+	for _, rendition := range image.Renditions {
+		done = done && manifest.Fresh(Output(rendition.Path), image.Raw, settings(rendition))
+		if rendition.WebP != "" {
+			done = done && manifest.Fresh(Output(rendition.WebP), image.Raw, webpsettings(rendition))
+		}
+	}
+	if done {
+		return true, nil
+	}
+
+	if err := gallery.Files.Fetch(image.Raw); err != nil {
+		return false, err
+	}
+	if renderer, ok := processor.(imgproc.Renderer); ok && rendersDirectly(image, renderer, webpformat) {
+		return renderPhoto(image, renderer, webpformat, settings, webpsettings)
+	}
+	m, err := processor.Load(image.Raw, sizes.Large())
+	if err != nil {
+		return false, err
+	}
+	m = imgproc.ApplyPreset(m, image.Preset)
+
+	// renditions are scaled from the next larger one, starting from the
+	// largest, which is much cheaper than scaling each from the source
+	for i := len(image.Renditions) - 1; i >= 0; i-- {
+		rendition := image.Renditions[i]
+		scaled := m
+		if i == 0 {
+			scaled = Thumbnail(m)
+			SetPlaceholders(image, scaled)
+		} else if ModeFor(rendition.Size).Mode != "fit" {
+			scaled = Resize(m, rendition.Size)
+			m = processor.Downscale(m, rendition.Size, FilterFor(rendition.Size))
+		} else {
+			scaled = processor.Downscale(m, rendition.Size, FilterFor(rendition.Size))
+			m = scaled
+		}
+		// the next rendition is scaled from the unsharpened image
+		scaled = Sharpen(scaled, rendition.Size)
+		rendition.Width, rendition.Height = scaled.Bounds().Dx(), scaled.Bounds().Dy()
+
+		name := Output(rendition.Path)
+		if !manifest.Fresh(name, image.Raw, settings(rendition)) {
+			stale = true
+			err := saveRendition(rendition, name, image.Raw, i == 0, func(file string) error {
+				return processor.Save(scaled, file, rendition.Format, rendition.Quality)
+			})
+			if err != nil {
+				errs.Add(err)
+			} else {
+				manifest.Record(name, image.Raw, settings(rendition))
+			}
+		}
+
+		if rendition.WebP == "" {
+			continue
+		}
+		webpname := Output(rendition.WebP)
+		if !manifest.Fresh(webpname, image.Raw, webpsettings(rendition)) {
+			stale = true
+			err := WriteOutputFile(webpname, func(file string) error {
+				return processor.Save(scaled, file, webpformat, 0)
+			})
+			if err != nil {
+				errs.Add(err)
+			} else {
+				manifest.Record(webpname, image.Raw, webpsettings(rendition))
+			}
+		}
+	}
+	return !stale, errs.Err()
+}
+
+// rendersDirectly returns whether the renditions of image can be rendered
+// straight from the source, i.e. they don't need processing in Go.
+func rendersDirectly(image *gallery.Image, renderer imgproc.Renderer, webpformat string) bool {
+	if _, ok := imgproc.Presets[image.Preset]; ok {
+		return false
+	}
+	for _, rendition := range image.Renditions {
+		if ModeFor(rendition.Size).Mode != "fit" || SharpenAmount(rendition.Size) > 0 ||
+			!renderer.Renders(image.Raw, rendition.Format) ||
+			(rendition.WebP != "" && !renderer.Renders(image.Raw, webpformat)) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderPhoto renders the stale renditions of image straight from the source,
+// the sizes and placeholders are read from the published files.
+func renderPhoto(image *gallery.Image, renderer imgproc.Renderer, webpformat string, settings, webpsettings func(*gallery.Rendition) string) (skipped bool, err error) {
+	var errs Errors
+	stale := false
+	for i, rendition := range image.Renditions {
+		name := Output(rendition.Path)
+		if !manifest.Fresh(name, image.Raw, settings(rendition)) {
+			stale = true
+			err := saveRendition(rendition, name, image.Raw, i == 0, func(file string) error {
+				return renderer.Render(image.Raw, file, rendition.Size, rendition.Format, rendition.Quality)
+			})
+			if err != nil {
+				errs.Add(err)
+			} else {
+				manifest.Record(name, image.Raw, settings(rendition))
+			}
+		}
+
+		if rendition.WebP == "" {
+			continue
+		}
+		webpname := Output(rendition.WebP)
+		if !manifest.Fresh(webpname, image.Raw, webpsettings(rendition)) {
+			stale = true
+			err := WriteOutputFile(webpname, func(file string) error {
+				return renderer.Render(image.Raw, file, rendition.Size, webpformat, 0)
+			})
+			if err != nil {
+				errs.Add(err)
+			} else {
+				manifest.Record(webpname, image.Raw, webpsettings(rendition))
+			}
+		}
+	}
+	return !stale, errs.Err()
+}
+
+// saveRendition encodes the rendition with encode and adds the kept metadata.
+func saveRendition(rendition *gallery.Rendition, name, source string, thumb bool, encode func(file string) error) error {
+	return WriteOutputFile(name, func(file string) error {
+		if err := encode(file); err != nil {
+			return err
+		}
+		if imgproc.FormatExt(rendition.Format) != ".jpg" {
+			return nil
+		}
+		// thumbnails are always published without metadata
+		if !thumb {
+			if err := imgproc.EmbedExif(file, source, KeptFields()); err != nil {
+				return err
+			}
+		}
+		if rendition.Progressive {
+			return imgproc.MakeProgressive(file)
+		}
+		return nil
+	})
+}
+
+// processOriginal publishes the original animation or vector image untouched
+// and creates a thumbnail from the first frame or the rasterized image.
+func processOriginal(image *gallery.Image) (skipped bool, err error) {
+	var errs Errors
+	stale := false
+
+	thumbname := Output(image.Thumb)
+	imagename := Output(image.Path)
+
+	thumbwebp := Output(image.ThumbWebP)
+	imagewebp := Output(image.WebP)
+
+	originalsettings := Settings("original")
+	if !manifest.Fresh(imagename, image.Raw, originalsettings) {
+		stale = true
+		if err := copySource(image, imagename); err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(imagename, image.Raw, originalsettings)
+		}
+	}
+
+	webpsettings := Settings("gif2webp")
+	if image.WebP != "" && !manifest.Fresh(imagewebp, image.Raw, webpsettings) {
+		stale = true
+		err := WriteOutputFile(imagewebp, func(file string) error {
+			if err := gallery.Files.Fetch(image.Raw); err != nil {
+				return err
+			}
+			return imgproc.ConvertGIFToWebP(image.Raw, file, imgproc.WebPQuality)
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(imagewebp, image.Raw, webpsettings)
+		}
+	}
+
+	thumbsettings := Settings("thumb", image.ThumbFormat)
+	thumbwebpsettings := Settings("thumb", "webp")
+	thumbDone := manifest.Fresh(thumbname, image.Raw, thumbsettings) &&
+		(image.ThumbWebP == "" || manifest.Fresh(thumbwebp, image.Raw, thumbwebpsettings))
+	if thumbDone {
+		return !stale, errs.Err()
+	}
+
+	if err := gallery.Files.Fetch(image.Raw); err != nil {
+		errs.Add(err)
+		return false, errs.Err()
+	}
+	first, err := imgproc.Load(image.Raw)
+	if err != nil {
+		errs.Add(err)
+		return false, errs.Err()
+	}
+
+	thumb := Thumbnail(first)
+	SetPlaceholders(image, thumb)
+	if !manifest.Fresh(thumbname, image.Raw, thumbsettings) {
+		stale = true
+		err := WriteOutputFile(thumbname, func(file string) error {
+			return imgproc.Save(thumb, file, image.ThumbFormat, JPEGQuality(sizes.Thumb()))
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(thumbname, image.Raw, thumbsettings)
+		}
+	}
+	if image.ThumbWebP != "" && !manifest.Fresh(thumbwebp, image.Raw, thumbwebpsettings) {
+		stale = true
+		err := WriteOutputFile(thumbwebp, func(file string) error {
+			return imgproc.SaveWebP(thumb, file, imgproc.WebPQuality)
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(thumbwebp, image.Raw, thumbwebpsettings)
+		}
+	}
+	return !stale, errs.Err()
+}
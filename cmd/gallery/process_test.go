@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// fakeRenderer renders with Go and records the rendered sizes.
+type fakeRenderer struct {
+	imgproc.Go
+	mu       sync.Mutex
+	rendered []int
+}
+
+func (renderer *fakeRenderer) Renders(path, format string) bool { return format != "avif" }
+
+func (renderer *fakeRenderer) Render(path, out string, size int, format string, quality int) error {
+	renderer.mu.Lock()
+	renderer.rendered = append(renderer.rendered, size)
+	renderer.mu.Unlock()
+
+	m, err := imgproc.Load(path)
+	if err != nil {
+		return err
+	}
+	return imgproc.Save(imgproc.Downscale(m, size), out, format, quality)
+}
+
+func TestRenderDirectly(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		config   string
+		rendered int
+	}{
+		{"plain", map[string]string{}, "", 2},
+		{"sharpened", map[string]string{"sharpen": "0.5"}, "", 0},
+		{"cropped thumbnails", map[string]string{"thumb-aspect": "1:1"}, "", 0},
+		{"unsupported format", map[string]string{"thumb-format": "avif"}, "", 0},
+		{"preset", map[string]string{}, "preset: bw\n", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "images")
+			output := filepath.Join(dir, "public")
+			writeTestImage(t, filepath.Join(source, "trip", "a.png"))
+			if test.config != "" {
+				err := ioutil.WriteFile(filepath.Join(source, "trip", gallery.ConfigName), []byte(test.config), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			flags := map[string]string{"source": source, "output": output, "quiet": "true"}
+			for name, value := range test.flags {
+				flags[name] = value
+			}
+			setFlags(t, flags)
+			if err := ValidateFlags(); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if T, err = LoadTemplates(); err != nil {
+				t.Fatal(err)
+			}
+
+			renderer := &fakeRenderer{}
+			previous := processor
+			processor = renderer
+			err = Build(false)
+			processor = previous
+			if len(renderer.rendered) != test.rendered {
+				t.Errorf("rendered %v, expected %d renditions", renderer.rendered, test.rendered)
+			}
+			if test.rendered == 0 {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"thumbs/trip/a.png", "images/trip/a.png"} {
+				if !FileExists(filepath.Join(output, filepath.FromSlash(name))) {
+					t.Errorf("%v not rendered", name)
+				}
+			}
+		})
+	}
+}
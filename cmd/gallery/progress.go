@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var noprogress = BuildFlags.Bool("no-progress", false, "log a line per image instead of showing the progress bar, it's the default when the output is not a terminal or with -verbose and -quiet")
+
+// progress tracks the current build, it's nil when images are not processed.
+var progress *Progress
+
+// Progress displays the number of processed images with the estimated
+// remaining time and counts the rendered pages.
+type Progress struct {
+	mu sync.Mutex
+
+	Total     int
+	Processed int
+	Skipped   int
+	Failed    int
+	Pages     int
+
+	bar    bool
+	start  time.Time
+	drawn  time.Time
+	output *os.File
+}
+
+// NewProgress starts tracking the processing of total images.
+func NewProgress(total int) *Progress {
+	return &Progress{
+		Total:  total,
+		bar:    !*noprogress && !*verbose && !*quiet && isTerminal(os.Stderr),
+		start:  time.Now(),
+		output: os.Stderr,
+	}
+}
+
+// isTerminal returns whether file is an interactive terminal.
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Image records a processed image.
+func (progress *Progress) Image(image *gallery.Image, skipped bool, elapsed time.Duration, err error) {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	progress.Processed++
+	switch {
+	case err != nil:
+		progress.Failed++
+	case skipped:
+		progress.Skipped++
+	}
+
+	if progress.bar && err != nil {
+		progress.clear()
+	}
+	switch {
+	case err != nil:
+		failures.Add("processing", image.Raw, err)
+	case skipped:
+		slog.Debug("up to date", "file", image.Raw)
+	case progress.bar:
+		slog.Debug("processed", "file", image.Raw, "duration", elapsed)
+	default:
+		slog.Info("processed", "file", image.Raw, "duration", elapsed)
+	}
+
+	if progress.bar && (err != nil || time.Since(progress.drawn) > 100*time.Millisecond) {
+		progress.draw()
+	}
+}
+
+// Page records a rendered page.
+func (progress *Progress) Page() {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	progress.Pages++
+}
+
+// ImagesDone removes the bar once the images are processed,
+// so that it's not mixed with the messages of later stages.
+func (progress *Progress) ImagesDone() {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	if progress.bar {
+		progress.clear()
+	}
+}
+
+// Done finishes the display with a summary.
+func (progress *Progress) Done() {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	slog.Info("build finished",
+		"images", progress.Processed, "skipped", progress.Skipped, "failed", progress.Failed,
+		"pages", progress.Pages, "duration", time.Since(progress.start).Round(time.Millisecond))
+}
+
+func (progress *Progress) clear() {
+	fmt.Fprint(progress.output, "\r\033[K")
+}
+
+func (progress *Progress) draw() {
+	progress.drawn = time.Now()
+
+	const width = 30
+	filled := width
+	if progress.Total > 0 {
+		filled = width * progress.Processed / progress.Total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+	eta := "?"
+	// skipped images are cheap, hence they are not used for the estimate
+	if done := progress.Processed - progress.Skipped; done > 0 {
+		remaining := progress.Total - progress.Processed
+		perImage := time.Since(progress.start) / time.Duration(done)
+		eta = (perImage * time.Duration(remaining)).Round(time.Second).String()
+	}
+
+	fmt.Fprintf(progress.output, "\r\033[K[%s] %d/%d images, %d skipped, %d failed, ETA %s",
+		bar, progress.Processed, progress.Total, progress.Skipped, progress.Failed, eta)
+}
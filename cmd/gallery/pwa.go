@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var (
+	pwa     = BuildFlags.Bool("pwa", false, "generate a web app manifest and a service worker for offline browsing")
+	pwaname = BuildFlags.String("pwa-name", "Galleries", "name of the installed web app")
+	pwaicon = BuildFlags.String("pwa-icon", "", "image used for the web app icons, defaults to the cover of the first gallery")
+)
+
+// PWAIconSizes are the generated web app icon sizes.
+var PWAIconSizes = []int{192, 512}
+
+// PWAEnabled returns whether the web app files are generated.
+func PWAEnabled() bool { return *pwa }
+
+// WebManifest is the web app manifest.
+type WebManifest struct {
+	Name            string       `json:"name"`
+	ShortName       string       `json:"short_name"`
+	StartURL        string       `json:"start_url"`
+	Display         string       `json:"display"`
+	BackgroundColor string       `json:"background_color"`
+	ThemeColor      string       `json:"theme_color"`
+	Icons           []WebAppIcon `json:"icons,omitempty"`
+}
+
+// WebAppIcon is an icon in the web app manifest.
+type WebAppIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// CreatePWA writes manifest.webmanifest, the icons and sw.js,
+// which precaches the pages and thumbnails of the galleries.
+func CreatePWA(galleries map[string]*gallery.Gallery) error {
+	webmanifest := WebManifest{
+		Name:            *pwaname,
+		ShortName:       *pwaname,
+		StartURL:        "/",
+		Display:         "standalone",
+		BackgroundColor: "#000000",
+		ThemeColor:      "#000000",
+	}
+
+	icons, err := CreatePWAIcons(pwaIconSource(galleries))
+	if err != nil {
+		return err
+	}
+	webmanifest.Icons = icons
+
+	data, err := json.MarshalIndent(webmanifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := WriteOutput(Output("manifest.webmanifest"), data); err != nil {
+		return err
+	}
+
+	precache := []string{"/", "/404.html", AssetLink("css/styles.css")}
+	for _, g := range galleries {
+		precache = append(precache, g.PageLink()+"/")
+		for _, image := range g.Images {
+			precache = append(precache, image.PageLink(), image.ThumbLink())
+		}
+	}
+	sort.Strings(precache[3:])
+
+	return WriteOutput(Output("sw.js"), ServiceWorker(precache))
+}
+
+// pwaIconSource returns the image the icons are created from.
+func pwaIconSource(galleries map[string]*gallery.Gallery) string {
+	if *pwaicon != "" {
+		return *pwaicon
+	}
+
+	var keys []string
+	for key, g := range galleries {
+		if g.Cover != nil && g.Cover.Kind == gallery.KindPhoto {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return Output(galleries[keys[0]].Cover.Path)
+}
+
+// CreatePWAIcons writes square icons cropped from source,
+// no icons are created when source is empty.
+func CreatePWAIcons(source string) ([]WebAppIcon, error) {
+	if source == "" {
+		return nil, nil
+	}
+	m, err := imgproc.Load(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var icons []WebAppIcon
+	for _, size := range PWAIconSizes {
+		icon := imaging.Fill(m, size, size, imaging.Center, imaging.Lanczos)
+
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, icon); err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("icons/icon-%d.png", size)
+		if err := WriteOutput(Output(name), buffer.Bytes()); err != nil {
+			return nil, err
+		}
+		icons = append(icons, WebAppIcon{
+			Src:   path.Join("/", name),
+			Sizes: fmt.Sprintf("%dx%d", size, size),
+			Type:  "image/png",
+		})
+	}
+	return icons, nil
+}
+
+// ServiceWorker returns a service worker that precaches the urls
+// and caches other requests as they are made. The cache name
+// changes with the urls, which discards the previous cache.
+func ServiceWorker(urls []string) []byte {
+	list, _ := json.Marshal(urls)
+	version := sha256.Sum256(list)
+	return []byte(strings.NewReplacer(
+		"$VERSION", fmt.Sprintf("%x", version[:4]),
+		"$URLS", string(list),
+	).Replace(serviceWorkerSource))
+}
+
+const serviceWorkerSource = `const CACHE = "gallery-$VERSION";
+const PRECACHE = $URLS;
+
+self.addEventListener("install", event => {
+	event.waitUntil(caches.open(CACHE).then(cache => cache.addAll(PRECACHE)).then(() => self.skipWaiting()));
+});
+
+self.addEventListener("activate", event => {
+	event.waitUntil(caches.keys().then(keys => Promise.all(
+		keys.filter(key => key !== CACHE).map(key => caches.delete(key))
+	)).then(() => self.clients.claim()));
+});
+
+self.addEventListener("fetch", event => {
+	if (event.request.method !== "GET") return;
+	event.respondWith(caches.open(CACHE).then(cache =>
+		cache.match(event.request).then(cached => cached || fetch(event.request).then(response => {
+			if (response.ok) cache.put(event.request, response.clone());
+			return response;
+		}).catch(() => caches.match("/404.html")))
+	));
+});
+`
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var (
+	sourcecache = SourceFlags.String("source-cache", "", "directory the originals of a remote -source are downloaded into, by default a directory in the user cache")
+	sourcejobs  = SourceFlags.Int("source-jobs", 8, "number of parallel downloads from a remote -source")
+)
+
+// IsRemote returns whether the source is an url instead of a directory.
+func IsRemote(source string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "http://", "https://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceCache returns the directory the remote source is downloaded into.
+func SourceCache(source string) (string, error) {
+	if *sourcecache != "" {
+		return *sourcecache, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("-source-cache must be set: %v", err)
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, "gallery", "source-"+hex.EncodeToString(sum[:8])), nil
+}
+
+// OpenSource lists the remote source, the galleries are read from the
+// storage and the originals are downloaded into the cache only when they
+// are processed. The downloads keep the remote modification time, hence
+// unchanged originals are not downloaded again. The cached files removed
+// from the source are removed from the cache.
+func OpenSource(source string) (*StorageFiles, error) {
+	remote, err := NewStorage(source)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := SourceCache(source)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := NewStorageFiles(remote, dir)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := files.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("source listed", "source", source, "cache", dir, "files", len(files.files), "removed", removed)
+	return files, nil
+}
+
+// download writes the remote file to local, unless it hasn't changed.
+func download(remote Storage, name, local string, since time.Time) (bool, error) {
+	body, modified, err := remote.Open(name, since)
+	if err == ErrNotModified {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	err = imgproc.WriteAtomic(local, func(tmp string) error {
+		file, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, body); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if modified.IsZero() {
+			return nil
+		}
+		return os.Chtimes(tmp, modified, modified)
+	})
+	return err == nil, err
+}
+
+// getModified executes a conditional GET request, prepare is called
+// after the headers are set.
+func getModified(client *http.Client, req *http.Request, since time.Time, prepare func(*http.Request)) (io.ReadCloser, time.Time, error) {
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, time.Time{}, ErrNotModified
+	}
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, time.Time{}, fmt.Errorf("GET %v: %v: %s", req.URL.Path, resp.Status, data)
+	}
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return resp.Body, modified, nil
+}
+
+// HTTPStorage reads the files from the directory listings of a web server,
+// e.g. nginx autoindex or Apache mod_autoindex. It can't be written to.
+type HTTPStorage struct {
+	Root   *url.URL
+	Client *http.Client
+}
+
+// listingLink matches the links of a directory listing.
+var listingLink = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+
+// List follows the links of the directory listings below the root,
+// the sizes aren't known, hence the files are described with HEAD requests.
+func (source *HTTPStorage) List() ([]StorageFile, error) {
+	var files []StorageFile
+	listed := map[string]bool{}
+	visited := map[string]bool{}
+	pending := []*url.URL{source.Root}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		if visited[dir.Path] {
+			continue
+		}
+		visited[dir.Path] = true
+
+		req, err := http.NewRequest("GET", dir.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, _, err := getModified(source.Client, req, time.Time{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range listingLink.FindAllSubmatch(data, -1) {
+			ref, err := url.Parse(html.UnescapeString(string(match[1])))
+			// sorting links and links to parents or other sites
+			if err != nil || ref.RawQuery != "" || ref.Fragment != "" {
+				continue
+			}
+			link := dir.ResolveReference(ref)
+			if link.Host != source.Root.Host || !strings.HasPrefix(link.Path, source.Root.Path) || link.Path == dir.Path {
+				continue
+			}
+			if strings.HasSuffix(link.Path, "/") {
+				if !gallery.IsIgnored(path.Base(link.Path)) {
+					pending = append(pending, link)
+				}
+				continue
+			}
+			if gallery.IsIgnored(path.Base(link.Path)) || listed[link.Path] {
+				continue
+			}
+			listed[link.Path] = true
+			files = append(files, StorageFile{
+				Name: strings.TrimPrefix(link.Path, source.Root.Path),
+				Size: -1,
+			})
+		}
+	}
+	return files, nil
+}
+
+// Open downloads the file.
+func (source *HTTPStorage) Open(name string, since time.Time) (io.ReadCloser, time.Time, error) {
+	link := source.Root.ResolveReference(&url.URL{Path: name})
+	req, err := http.NewRequest("GET", link.String(), nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return getModified(source.Client, req, since, nil)
+}
+
+// Describe requests the headers of the file for its size and modification time.
+func (source *HTTPStorage) Describe(name string) (StorageFile, error) {
+	link := source.Root.ResolveReference(&url.URL{Path: name})
+	req, err := http.NewRequest("HEAD", link.String(), nil)
+	if err != nil {
+		return StorageFile{}, err
+	}
+	client := source.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return StorageFile{}, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return StorageFile{}, fmt.Errorf("HEAD %v: %v", req.URL.Path, resp.Status)
+	}
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return StorageFile{Name: name, Size: resp.ContentLength, Modified: modified}, nil
+}
+
+// Put fails, the listings are read-only.
+func (source *HTTPStorage) Put(name string, data []byte) error {
+	return fmt.Errorf("%v: http storage is read-only", name)
+}
+
+// Delete fails, the listings are read-only.
+func (source *HTTPStorage) Delete(name string) error {
+	return fmt.Errorf("%v: http storage is read-only", name)
+}
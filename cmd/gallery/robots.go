@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CreateRobots writes robots.txt, which points crawlers to the sitemap.
+// Unpublished galleries aren't generated, hence they aren't listed,
+// which would reveal their names.
+func CreateRobots() error {
+	var buffer bytes.Buffer
+	fmt.Fprintln(&buffer, "User-agent: *")
+	fmt.Fprintln(&buffer, "Disallow:")
+	if *baseurl != "" {
+		fmt.Fprintf(&buffer, "\nSitemap: %v\n", AbsoluteURL("/sitemap.xml"))
+	}
+
+	return WriteOutput(Output("robots.txt"), buffer.Bytes())
+}
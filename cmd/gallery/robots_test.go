@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/egonelbre/gallery"
+)
+
+// testImage returns a small PNG.
+func testImage(t *testing.T) []byte {
+	t.Helper()
+	m := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i)
+	}
+	m.Set(0, 0, color.White)
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, m); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// writeTestImage writes a small PNG to path.
+func writeTestImage(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, testImage(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setFlags sets the flags for the duration of the test.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := LookupFlag(name)
+		previous := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Value.Set(previous) })
+	}
+}
+
+func TestRobotsOmitsUnlisted(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "images")
+	output := filepath.Join(dir, "public")
+
+	writeTestImage(t, filepath.Join(source, "public", "a.png"))
+	writeTestImage(t, filepath.Join(source, "secret", "a.png"))
+	writeTestImage(t, filepath.Join(source, "secret", "_draft", "b.png"))
+	writeTestImage(t, filepath.Join(source, "secret", "private", "c.png"))
+	err := ioutil.WriteFile(filepath.Join(source, "secret", gallery.ConfigName), []byte("visibility: unlisted\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(source, "secret", "private", gallery.ConfigName), []byte("visibility: private\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setFlags(t, map[string]string{
+		"source":   source,
+		"output":   output,
+		"base-url": "https://example.com",
+		"quiet":    "true",
+	})
+	secret := gallery.UnlistedSecretFile
+	gallery.UnlistedSecretFile = filepath.Join(dir, "unlisted-secret")
+	t.Cleanup(func() { gallery.UnlistedSecretFile = secret })
+
+	if err := ValidateFlags(); err != nil {
+		t.Fatal(err)
+	}
+	if T, err = LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Build(false); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := filepath.Glob(filepath.Join(output, "*", "secret", "index.html"))
+	if err != nil || len(pages) != 1 {
+		t.Fatalf("unlisted gallery page not found: %v %v", pages, err)
+	}
+	token := filepath.Base(filepath.Dir(filepath.Dir(pages[0])))
+
+	robots, err := ioutil.ReadFile(filepath.Join(output, "robots.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{token, "secret", "draft", "private"} {
+		if strings.Contains(string(robots), leak) {
+			t.Errorf("robots.txt contains %q:\n%s", leak, robots)
+		}
+	}
+}
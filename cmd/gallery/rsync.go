@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	rsync    = DeployFlags.String("rsync", "rsync", "path to rsync for deploying over SSH")
+	rsyncssh = DeployFlags.String("rsync-ssh", "ssh", "remote shell used by rsync")
+)
+
+// RsyncDeployer publishes the site with rsync, either over SSH
+// for ssh://user@host:port/path targets or to an rsync daemon
+// for rsync://host/module/path targets.
+type RsyncDeployer struct {
+	// Destination is the directory in rsync syntax.
+	Destination string
+	// Shell is the remote shell, empty for rsync daemons.
+	Shell string
+}
+
+// NewRsyncDeployer creates a deployer for an ssh:// or rsync:// target.
+func NewRsyncDeployer(u *url.URL) (*RsyncDeployer, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("host missing from %q", u.String())
+	}
+
+	if u.Scheme == "rsync" {
+		return &RsyncDeployer{Destination: strings.TrimSuffix(u.String(), "/") + "/"}, nil
+	}
+
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	if path == "" {
+		path = "."
+	}
+
+	shell := *rsyncssh
+	if port := u.Port(); port != "" {
+		shell += " -p " + port
+	}
+	return &RsyncDeployer{
+		Destination: host + ":" + strings.TrimSuffix(path, "/") + "/",
+		Shell:       shell,
+	}, nil
+}
+
+// Sync uploads the changed files of dir listed in upload and deletes
+// the remote files listed in remove, each with a single rsync run.
+func (deployer *RsyncDeployer) Sync(dir string, upload, remove []string) error {
+	if len(upload) > 0 {
+		if err := deployer.run(dir, upload); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		// the files in remove don't exist locally, hence they are deleted
+		if err := deployer.run(dir, remove, "--delete-missing-args"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run transfers the files in names from dir to the destination.
+func (deployer *RsyncDeployer) run(dir string, names []string, extra ...string) error {
+	list, err := ioutil.TempFile("", "gallery-rsync-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list.Name())
+
+	_, err = list.WriteString(strings.Join(names, "\n") + "\n")
+	if closeErr := list.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-a", "--itemize-changes", "--files-from=" + list.Name()}
+	args = append(args, deployer.shellArgs()...)
+	args = append(args, extra...)
+	args = append(args, strings.TrimSuffix(dir, "/")+"/", deployer.Destination)
+
+	cmd := exec.Command(*rsync, args...)
+	cmd.Stdout = os.Stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("rsync %v: %v: %s", *rsync, err, stderr.Bytes())
+	}
+	return nil
+}
+
+func (deployer *RsyncDeployer) shellArgs() []string {
+	if deployer.Shell == "" {
+		return nil
+	}
+	return []string{"-e", deployer.Shell}
+}
+
+// rsyncListing matches a line of rsync --list-only output.
+var rsyncListing = regexp.MustCompile(`^(\S+)\s+[\d,.]+\s+\S+\s+\S+\s+(.+)$`)
+
+// List returns the files in the destination.
+func (deployer *RsyncDeployer) List() ([]string, error) {
+	args := []string{"-r", "--list-only"}
+	args = append(args, deployer.shellArgs()...)
+	args = append(args, deployer.Destination)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(*rsync, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rsync %v: %v: %s", *rsync, err, stderr.Bytes())
+	}
+
+	var names []string
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		match := rsyncListing.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil || strings.HasPrefix(match[1], "d") || match[2] == "." {
+			continue
+		}
+		names = append(names, match[2])
+	}
+	return names, nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the S3 settings are used by remote sources as well as deploys
+var (
+	s3endpoint = SourceFlags.String("s3-endpoint", "https://s3.amazonaws.com", "endpoint of the S3 compatible storage")
+	s3region   = SourceFlags.String("s3-region", "us-east-1", "region of the S3 bucket")
+)
+
+// S3 is a bucket on an S3 compatible storage,
+// requests are signed with AWS Signature Version 4.
+type S3 struct {
+	Endpoint string
+	Region   string
+	Bucket   string
+	Prefix   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	Client *http.Client
+}
+
+// NewS3 creates a client for an s3://bucket/prefix url, the credentials
+// are read from the AWS environment variables. Google Cloud Storage
+// gs://bucket/prefix urls use its S3 compatible API with the HMAC keys
+// from GS_ACCESS_KEY_ID and GS_SECRET_ACCESS_KEY.
+func NewS3(u *url.URL) (*S3, error) {
+	s3 := &S3{
+		Endpoint:     *s3endpoint,
+		Region:       *s3region,
+		Bucket:       u.Host,
+		Prefix:       strings.Trim(u.Path, "/"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if u.Scheme == "gs" {
+		s3.Endpoint, s3.Region = "https://storage.googleapis.com", "auto"
+		s3.AccessKey, s3.SecretKey = os.Getenv("GS_ACCESS_KEY_ID"), os.Getenv("GS_SECRET_ACCESS_KEY")
+		s3.SessionToken = ""
+		if s3.AccessKey == "" || s3.SecretKey == "" {
+			return nil, fmt.Errorf("GS_ACCESS_KEY_ID and GS_SECRET_ACCESS_KEY must be set")
+		}
+		return s3, nil
+	}
+	if s3.AccessKey == "" || s3.SecretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return s3, nil
+}
+
+// Put uploads data to the key.
+func (s3 *S3) Put(key string, data []byte, contentType, cacheControl string) error {
+	req, err := s3.request("PUT", key, nil, data)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if cacheControl != "" {
+		req.Header.Set("Cache-Control", cacheControl)
+	}
+	return s3.do(req, data, nil)
+}
+
+// Delete removes the key.
+func (s3 *S3) Delete(key string) error {
+	req, err := s3.request("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	return s3.do(req, nil, nil)
+}
+
+// S3Object is an entry in the bucket listing.
+type S3Object struct {
+	Key          string    `xml:"Key"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// Get downloads the key, unless it hasn't been modified since the
+// given time, which returns ErrNotModified. The caller closes the body.
+func (s3 *S3) Get(key string, since time.Time) (io.ReadCloser, time.Time, error) {
+	req, err := s3.request("GET", key, nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return getModified(s3.Client, req, since, func(req *http.Request) {
+		s3.sign(req, nil, time.Now().UTC())
+	})
+}
+
+// List returns the objects with the configured prefix.
+func (s3 *S3) List() ([]S3Object, error) {
+	var objects []S3Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if s3.Prefix != "" {
+			query.Set("prefix", s3.Prefix+"/")
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := s3.request("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents              []S3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		if err := s3.do(req, nil, &result); err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Contents...)
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Key returns the object key for a file name relative to the site root.
+func (s3 *S3) Key(name string) string {
+	if s3.Prefix == "" {
+		return name
+	}
+	return s3.Prefix + "/" + name
+}
+
+// request creates a path-style request for key.
+func (s3 *S3) request(method, key string, query url.Values, body []byte) (*http.Request, error) {
+	endpoint, err := url.Parse(s3.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/" + s3.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	endpoint.Path = path
+	endpoint.RawPath = uriEncode(path, false)
+	endpoint.RawQuery = canonicalQuery(query)
+
+	return http.NewRequest(method, endpoint.String(), bytes.NewReader(body))
+}
+
+// do signs and executes the request and decodes an XML response into result.
+func (s3 *S3) do(req *http.Request, body []byte, result interface{}) error {
+	s3.sign(req, body, time.Now().UTC())
+
+	client := s3.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("s3 %v %v: %v: %s", req.Method, req.URL.Path, resp.Status, data)
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(result)
+}
+
+// sign adds the AWS Signature Version 4 authorization to the request.
+func (s3 *S3) sign(req *http.Request, body []byte, now time.Time) {
+	amzdate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzdate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s3.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s3.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "cache-control" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + s3.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzdate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s3.SecretKey), date)
+	key = hmacSHA256(key, s3.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s3.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes the query sorted by key as required by SigV4.
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything except the unreserved characters,
+// slashes are kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var addr = ServeFlags.String("addr", "localhost:8080", "address to listen on with serve")
+
+// mimeTypes contains the types that are missing from some systems.
+var mimeTypes = map[string]string{
+	".avif":        "image/avif",
+	".webp":        "image/webp",
+	".svg":         "image/svg+xml",
+	".mp4":         "video/mp4",
+	".mov":         "video/quicktime",
+	".json":        "application/json",
+	".geojson":     "application/geo+json",
+	".gpx":         "application/gpx+xml",
+	".kml":         "application/vnd.google-earth.kml+xml",
+	".xml":         "application/xml",
+	".webmanifest": "application/manifest+json",
+	".zip":         "application/zip",
+}
+
+var registerMimeTypes sync.Once
+
+// RegisterMimeTypes adds mimeTypes to the known MIME types.
+func RegisterMimeTypes() {
+	registerMimeTypes.Do(func() {
+		for ext, typ := range mimeTypes {
+			mime.AddExtensionType(ext, typ)
+		}
+	})
+}
+
+// Serve serves the generated site from the output directory,
+// with live the pages reload after rebuilds. With -admin photos
+// can be uploaded at AdminPath.
+func Serve(addr string, live bool) error {
+	RegisterMimeTypes()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", siteHandler(*outputdir, live))
+	if live {
+		mux.Handle(LiveReloadPath, reloader)
+	}
+	if *admin {
+		handler, err := NewAdmin(live)
+		if err != nil {
+			return err
+		}
+		mux.Handle(AdminPath, handler)
+		slog.Info("admin page", "url", "http://"+addr+AdminPath)
+	}
+
+	slog.Info("serving", "url", "http://"+addr+"/")
+	return http.ListenAndServe(addr, mux)
+}
+
+// siteHandler serves files from dir and 404.html for missing pages,
+// with live the live reload script is added to pages.
+func siteHandler(dir string, live bool) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			name = filepath.Join(name, "index.html")
+		}
+
+		if !FileExists(name) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			if data, err := ioutil.ReadFile(filepath.Join(dir, "404.html")); err == nil {
+				if live {
+					data = InjectLiveReload(data)
+				}
+				w.Write(data)
+			}
+			return
+		}
+
+		if live && filepath.Ext(name) == ".html" && !strings.HasSuffix(r.URL.Path, "/index.html") {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write(InjectLiveReload(data))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
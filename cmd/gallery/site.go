@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// site is the storage the site is generated into, see Site.
+var site Storage
+
+// OpenSite opens the -output location. A directory is written in place,
+// the files of a bucket are created in a temporary directory and uploaded.
+func OpenSite() error {
+	storage, err := NewStorage(*outputdir)
+	if err != nil {
+		return err
+	}
+	switch storage := storage.(type) {
+	case DirStorage:
+		*outputdir = string(storage)
+	case *HTTPStorage:
+		return fmt.Errorf("-output %v is read-only", *outputdir)
+	}
+	site = storage
+	return nil
+}
+
+// Site returns the storage the site is generated into,
+// the -output directory unless OpenSite opened another storage.
+func Site() Storage {
+	if site == nil {
+		return DirStorage(*outputdir)
+	}
+	return site
+}
+
+// LocalSite returns an error when the site isn't generated into a directory,
+// the command needs to read the generated files.
+func LocalSite(command string) error {
+	if _, ok := Site().(LocalStorage); !ok {
+		return fmt.Errorf("%v needs a local -output directory", command)
+	}
+	return nil
+}
+
+// siteName returns the slash separated name of the output path in the site.
+func siteName(path string) string {
+	rel, err := filepath.Rel(filepath.Clean(*outputdir), path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// siteFiles are the files of a site that isn't local,
+// they are listed once instead of checking each output.
+var siteFiles = struct {
+	sync.Mutex
+	files map[string]StorageFile
+}{}
+
+// ListSite lists the existing files of a site that isn't local.
+func ListSite() error {
+	siteFiles.Lock()
+	defer siteFiles.Unlock()
+	siteFiles.files = nil
+	if _, ok := Site().(LocalStorage); ok {
+		return nil
+	}
+
+	files, err := Site().List()
+	if err != nil {
+		return err
+	}
+	siteFiles.files = map[string]StorageFile{}
+	for _, file := range files {
+		siteFiles.files[file.Name] = file
+	}
+	return nil
+}
+
+// putSite writes the file into the site.
+func putSite(name string, data []byte) error {
+	if err := Site().Put(name, data); err != nil {
+		return err
+	}
+	siteFiles.Lock()
+	if siteFiles.files != nil {
+		siteFiles.files[name] = StorageFile{Name: name, Size: int64(len(data)), Modified: time.Now()}
+	}
+	siteFiles.Unlock()
+	return nil
+}
+
+// WriteOutputFile creates the output at path with write, which is given
+// the file to create. The files of a site that isn't local are created
+// in a temporary directory and uploaded.
+func WriteOutputFile(path string, write func(file string) error) error {
+	MarkOutput(path)
+	name := siteName(path)
+	if local, ok := Site().(LocalStorage); ok {
+		file, err := local.Local(name)
+		if err != nil {
+			return err
+		}
+		return imgproc.WriteAtomic(file, write)
+	}
+
+	dir, err := ioutil.TempDir("", "gallery-output")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// the encoders pick the format by the extension
+	file := filepath.Join(dir, filepath.Base(path))
+	if err := write(file); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return putSite(name, data)
+}
+
+// OutputStat returns the information of the output at path.
+func OutputStat(path string) (os.FileInfo, error) {
+	name := siteName(path)
+	if local, ok := Site().(LocalStorage); ok {
+		file, err := local.Local(name)
+		if err != nil {
+			return nil, err
+		}
+		return os.Lstat(file)
+	}
+
+	siteFiles.Lock()
+	file, ok := siteFiles.files[name]
+	siteFiles.Unlock()
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	return storageInfo{file: file}, nil
+}
+
+// OutputExists returns whether the output at path exists.
+func OutputExists(path string) bool {
+	_, err := OutputStat(path)
+	return err == nil
+}
+
+// ReadOutput reads the output at path.
+func ReadOutput(path string) ([]byte, error) {
+	body, _, err := Site().Open(siteName(path), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// LocalOutput returns a local file with the output at path, done removes
+// the downloaded copy of a site that isn't local.
+func LocalOutput(path string) (file string, done func(), err error) {
+	name := siteName(path)
+	if local, ok := Site().(LocalStorage); ok {
+		file, err := local.Local(name)
+		return file, func() {}, err
+	}
+
+	body, _, err := Site().Open(name, time.Time{})
+	if err != nil {
+		return "", nil, err
+	}
+	defer body.Close()
+
+	dir, err := ioutil.TempDir("", "gallery-output")
+	if err != nil {
+		return "", nil, err
+	}
+	done = func() { os.RemoveAll(dir) }
+
+	file = filepath.Join(dir, filepath.Base(path))
+	out, err := os.Create(file)
+	if err == nil {
+		_, err = io.Copy(out, body)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		done()
+		return "", nil, err
+	}
+	return file, done, nil
+}
+
+// DecodeOutput decodes the image published at path.
+func DecodeOutput(path string) (image.Image, error) {
+	file, done, err := LocalOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	m, _, err := imgproc.Decode(file)
+	return m, err
+}
+
+// OutputSize returns the dimensions of the image published at path
+// by reading its header.
+func OutputSize(path string) (width, height int) {
+	body, _, err := Site().Open(siteName(path), time.Time{})
+	if err != nil {
+		return 0, 0
+	}
+	defer body.Close()
+	return imgproc.SizeOf(body)
+}
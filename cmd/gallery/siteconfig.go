@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// SiteConfigNames are the configuration files loaded from the working directory.
+var SiteConfigNames = []string{"gallery.yaml", "gallery.yml", "gallery.toml"}
+
+var configfile = CommonFlags.String("config", "", "configuration file with flag values, by default gallery.yaml or gallery.toml when present")
+
+// FindSiteConfig returns the configuration file to load,
+// it returns "" when there isn't one.
+func FindSiteConfig() string {
+	if *configfile != "" {
+		return *configfile
+	}
+	for _, name := range SiteConfigNames {
+		if FileExists(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// LoadSiteConfig reads the configuration file at path.
+//
+// The keys are flag names, e.g. "sizes" or "base-url", lists are
+// joined with commas and maps are formatted as "key=value" pairs.
+func LoadSiteConfig(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("%v: unknown configuration format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	values := map[string]string{}
+	for key, value := range raw {
+		s, err := configValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v: %v", path, key, err)
+		}
+		values[key] = s
+	}
+	return values, nil
+}
+
+// configValue formats a configuration value as a flag value.
+func configValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = item
+		}
+		return configValue(converted)
+	case map[string]interface{}:
+		var keys []string
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var items []string
+		for _, key := range keys {
+			s, err := configValue(value[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, key+"="+s)
+		}
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// ApplySiteConfig sets the flags from the configuration file, the explicit
+// flags given on the command line take precedence. The file may contain
+// the settings of every command.
+func ApplySiteConfig(explicit map[string]bool) error {
+	path := FindSiteConfig()
+	if path == "" {
+		return nil
+	}
+
+	values, err := LoadSiteConfig(path)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f := LookupFlag(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("%v: unknown setting %q", path, key)
+		}
+		if explicit[key] {
+			continue
+		}
+		if err := f.Value.Set(values[key]); err != nil {
+			return fmt.Errorf("%v: %v: %v", path, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+// Sitemap is a sitemaps.org url set.
+type Sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// CreateSitemap writes sitemap.xml for the index, gallery and image pages.
+func CreateSitemap(galleries map[string]*gallery.Gallery) {
+	if *baseurl == "" {
+		slog.Warn("sitemap skipped: -base-url not specified")
+		return
+	}
+
+	var pages []SitemapURL
+	var newest time.Time
+	for _, g := range galleries {
+		var updated time.Time
+		for _, image := range g.Images {
+			modified := image.Info.ModTime()
+			if modified.After(updated) {
+				updated = modified
+			}
+			pages = append(pages, SitemapURL{Loc: image.PageLink(), LastMod: modified.UTC().Format(time.RFC3339)})
+		}
+		if updated.After(newest) {
+			newest = updated
+		}
+		page := SitemapURL{Loc: g.PageLink() + "/"}
+		if !updated.IsZero() {
+			page.LastMod = updated.UTC().Format(time.RFC3339)
+		}
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, k int) bool { return pages[i].Loc < pages[k].Loc })
+
+	sitemap := Sitemap{}
+	sitemap.URLs = append(sitemap.URLs, SitemapURL{Loc: AbsoluteURL("/"), LastMod: newest.UTC().Format(time.RFC3339)})
+	for _, page := range pages {
+		page.Loc = AbsoluteURL(page.Loc)
+		sitemap.URLs = append(sitemap.URLs, page)
+	}
+
+	data, err := xml.MarshalIndent(sitemap, "", "\t")
+	if err != nil {
+		failures.Add("sitemap", "sitemap.xml", err)
+		return
+	}
+	err = WriteOutput(Output("sitemap.xml"), append([]byte(xml.Header), data...))
+	if err != nil {
+		failures.Add("sitemap", "sitemap.xml", err)
+	}
+}
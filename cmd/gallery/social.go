@@ -0,0 +1,107 @@
+package main
+
+import (
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// Social is the metadata used by OpenGraph and Twitter Cards
+// when a page is shared.
+type Social struct {
+	Type        string
+	Title       string
+	Description string
+	URL         string
+
+	// Image is the absolute url of the preview image.
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+	ImageAlt    string
+}
+
+// Card returns the Twitter Card type.
+func (social *Social) Card() string {
+	if social.Image != "" {
+		return "summary_large_image"
+	}
+	return "summary"
+}
+
+// socialSize is the preferred width of the shared image.
+const socialSize = 1200
+
+// SocialImage picks the published file used as the preview of image,
+// social networks don't understand WebP or AVIF reliably, so only
+// JPEG and PNG renditions are considered.
+func SocialImage(image *gallery.Image) (link string, width, height int) {
+	var best *gallery.Rendition
+	for _, rendition := range image.Renditions {
+		if rendition.Width == 0 || (rendition.Format != "jpg" && rendition.Format != "png") {
+			continue
+		}
+		if best == nil || best.Width < socialSize {
+			best = rendition
+		}
+	}
+	if best != nil {
+		return best.Link(), best.Width, best.Height
+	}
+
+	file := image.Thumb
+	if image.Poster != "" {
+		file = image.Poster
+	}
+	width, height = imgproc.Size(Output(file))
+	return "/" + filepath.ToSlash(file), width, height
+}
+
+func (social *Social) setImage(image *gallery.Image) {
+	if image == nil {
+		return
+	}
+	link, width, height := SocialImage(image)
+	social.Image = AbsoluteURL(link)
+	social.ImageWidth, social.ImageHeight = width, height
+	social.ImageAlt = image.Title
+}
+
+// ImageSocial returns the social metadata for an image page.
+func ImageSocial(g *gallery.Gallery, image *gallery.Image) *Social {
+	social := &Social{
+		Type:        "article",
+		Title:       image.Title,
+		Description: image.Caption,
+		URL:         AbsoluteURL(image.PageLink()),
+	}
+	if social.Description == "" {
+		social.Description = g.Title
+	}
+	social.setImage(image)
+	return social
+}
+
+// GallerySocial returns the social metadata for a gallery page.
+func GallerySocial(g *gallery.Gallery) *Social {
+	social := &Social{
+		Type:        "website",
+		Title:       g.Title,
+		Description: PlainText(string(g.Description)),
+		URL:         AbsoluteURL(g.PageLink() + "/"),
+	}
+	social.setImage(g.Cover)
+	return social
+}
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// PlainText converts rendered html into a single line of text.
+func PlainText(s string) string {
+	s = htmlTags.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+// StorageFiles reads the galleries from a storage, the files appear in Dir.
+// Opening a file streams it from the storage, fetching downloads it into
+// Dir, a downloaded file is downloaded again only when it changes.
+// Nothing is downloaded when listing the storage.
+type StorageFiles struct {
+	Storage Storage
+	Dir     string
+
+	files map[string]StorageFile
+	dirs  map[string][]string
+
+	mu       sync.Mutex
+	fetching map[string]*sync.Mutex
+}
+
+// NewStorageFiles lists the files in storage, they appear in dir.
+func NewStorageFiles(storage Storage, dir string) (*StorageFiles, error) {
+	dir = filepath.Clean(dir)
+	list, err := storage.List()
+	if err != nil {
+		return nil, err
+	}
+
+	files := &StorageFiles{
+		Storage:  storage,
+		Dir:      dir,
+		files:    map[string]StorageFile{},
+		dirs:     map[string][]string{dir: nil},
+		fetching: map[string]*sync.Mutex{},
+	}
+	for _, file := range list {
+		local := filepath.FromSlash(file.Name)
+		if !filepath.IsLocal(local) {
+			slog.Warn("skipping file outside of the source", "file", file.Name)
+			continue
+		}
+		path := filepath.Join(dir, local)
+		files.files[path] = file
+		files.addEntry(path)
+	}
+	for _, entries := range files.dirs {
+		sort.Strings(entries)
+	}
+
+	if err := files.describeUnknown(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// addEntry adds path to its directory, creating the missing directories.
+func (files *StorageFiles) addEntry(path string) {
+	for path != files.Dir {
+		parent := filepath.Dir(path)
+		_, exists := files.dirs[parent]
+		files.dirs[parent] = append(files.dirs[parent], path)
+		if exists {
+			return
+		}
+		path = parent
+	}
+}
+
+// describeUnknown requests the sizes and modification times of the
+// files listed without them, i.e. from web server listings. The files
+// the storage can't describe are described by their downloaded copies.
+func (files *StorageFiles) describeUnknown() error {
+	storage, ok := files.Storage.(DescribingStorage)
+	if !ok {
+		return nil
+	}
+
+	var unknown []string
+	for path, file := range files.files {
+		if !known(file) {
+			unknown = append(unknown, path)
+		}
+	}
+
+	described := make([]StorageFile, len(unknown))
+	errs := make([]error, len(unknown))
+	async.Iter(len(unknown), *sourcejobs, func(i int) {
+		name := files.files[unknown[i]].Name
+		file, err := storage.Describe(name)
+		if err != nil {
+			errs[i] = fmt.Errorf("%v: %v", name, err)
+		}
+		described[i] = file
+	})
+
+	for i, path := range unknown {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		files.files[path] = described[i]
+	}
+	return nil
+}
+
+// known returns whether the size and modification time of the file are known.
+func known(file StorageFile) bool {
+	return file.Size >= 0 && !file.Modified.IsZero()
+}
+
+// file returns the listed file at path.
+func (files *StorageFiles) file(path string) (StorageFile, bool) {
+	files.mu.Lock()
+	defer files.mu.Unlock()
+	file, ok := files.files[path]
+	return file, ok
+}
+
+// Prune removes the downloaded files that aren't in the storage anymore
+// and returns how many were removed.
+func (files *StorageFiles) Prune() (int, error) {
+	removed := 0
+	err := filepath.Walk(files.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if _, ok := files.files[path]; ok {
+			return nil
+		}
+		slog.Debug("removing from the source cache", "file", path)
+		removed++
+		return os.Remove(path)
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return removed, err
+}
+
+// Walk calls fn for the files and directories in root like filepath.Walk.
+func (files *StorageFiles) Walk(root string, fn filepath.WalkFunc) error {
+	root = filepath.Clean(root)
+	info, err := files.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = files.walk(root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walk walks path in the order of filepath.Walk.
+func (files *StorageFiles) walk(path string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if err := fn(path, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	for _, child := range files.dirs[path] {
+		info, err := files.Stat(child)
+		if err != nil {
+			return err
+		}
+		if err := files.walk(child, info, fn); err != nil {
+			if err == filepath.SkipDir && info.IsDir() {
+				continue
+			}
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat returns the listed information of the file or directory at path.
+func (files *StorageFiles) Stat(path string) (os.FileInfo, error) {
+	path = filepath.Clean(path)
+	if file, ok := files.file(path); ok {
+		return storageInfo{file: file}, nil
+	}
+	if _, ok := files.dirs[path]; ok {
+		rel, _ := filepath.Rel(files.Dir, path)
+		return storageInfo{file: StorageFile{Name: filepath.ToSlash(rel)}, dir: true}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+}
+
+// Open opens the downloaded copy of the file at path when it's up to date,
+// otherwise the file is streamed from the storage.
+func (files *StorageFiles) Open(path string) (io.ReadCloser, error) {
+	path = filepath.Clean(path)
+	file, ok := files.file(path)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	if downloaded(path, file) {
+		return os.Open(path)
+	}
+	body, _, err := files.Storage.Open(file.Name, time.Time{})
+	return body, err
+}
+
+// Fetch downloads the file at path, unless the downloaded copy is up to date.
+// A file without a known size and time is downloaded when it has changed
+// since the downloaded copy and described by the copy.
+func (files *StorageFiles) Fetch(path string) error {
+	path = filepath.Clean(path)
+	if _, ok := files.file(path); !ok {
+		return &os.PathError{Op: "fetch", Path: path, Err: os.ErrNotExist}
+	}
+
+	files.mu.Lock()
+	lock, ok := files.fetching[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		files.fetching[path] = lock
+	}
+	files.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	file, _ := files.file(path)
+	if downloaded(path, file) {
+		return nil
+	}
+
+	var since time.Time
+	if info, err := os.Stat(path); err == nil && !known(file) {
+		since = info.ModTime()
+	}
+	fetched, err := download(files.Storage, file.Name, path, since)
+	if err != nil {
+		return err
+	}
+	if fetched {
+		slog.Debug("downloaded", "file", file.Name)
+	}
+
+	if !known(file) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		files.mu.Lock()
+		files.files[path] = StorageFile{Name: file.Name, Size: info.Size(), Modified: info.ModTime()}
+		files.mu.Unlock()
+	}
+	return nil
+}
+
+// downloaded returns whether the local copy at path is the listed file.
+func downloaded(path string, file StorageFile) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == file.Size && !file.Modified.After(info.ModTime())
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var (
+	stackwindow   = BuildFlags.Duration("stack-window", 0, "stack similar photos taken within the duration of each other, e.g. 2s; the first photo is shown in the gallery and the rest on its page, 0 disables stacking")
+	stackdistance = BuildFlags.Int("stack-distance", 10, "maximum number of differing bits (0-64) in the perceptual hashes of stacked photos")
+)
+
+// CreateStacks groups the bursts of similar photos in the gallery,
+// the photos are compared using their thumbnails.
+func CreateStacks(g *gallery.Gallery) {
+	if *stackwindow <= 0 {
+		return
+	}
+
+	hashes := map[*gallery.Image]uint64{}
+	for _, image := range g.Images {
+		if image.Kind != gallery.KindPhoto {
+			continue
+		}
+		thumb, err := DecodeOutput(Output(image.Thumb))
+		if err != nil {
+			slog.Warn("photo not stacked, thumbnail can't be decoded", "file", image.Thumb, "err", err)
+			continue
+		}
+		hashes[image] = imgproc.DHash(thumb)
+	}
+	gallery.StackImages(g.Images, hashes, *stackwindow, *stackdistance)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// ErrNotModified is returned when a file hasn't changed.
+var ErrNotModified = errors.New("not modified")
+
+// StorageFile is a file in a storage.
+type StorageFile struct {
+	// Name is slash separated and relative to the storage root.
+	Name string
+	// Size and Modified are unknown when negative or zero.
+	Size     int64
+	Modified time.Time
+}
+
+// Storage is a tree of files in a local directory, a bucket or on a web
+// server. The remote sources are read and the sites deployed through it.
+type Storage interface {
+	// List returns the files in the storage.
+	List() ([]StorageFile, error)
+	// Open reads the file and returns its modification time,
+	// ErrNotModified when it hasn't changed since the given time.
+	Open(name string, since time.Time) (io.ReadCloser, time.Time, error)
+	// Put writes the file.
+	Put(name string, data []byte) error
+	// Delete removes the file.
+	Delete(name string) error
+}
+
+// LocalStorage is a storage whose files are local, they can be written
+// in place by the encoders and external programs.
+type LocalStorage interface {
+	Storage
+	// Local returns the local path of the file.
+	Local(name string) (string, error)
+}
+
+// DescribingStorage is a storage whose listing doesn't contain the sizes
+// and modification times of the files, they are requested per file.
+type DescribingStorage interface {
+	Storage
+	// Describe returns the size and modification time of the file,
+	// they are unknown when the storage doesn't report them.
+	Describe(name string) (StorageFile, error)
+}
+
+// NewStorage creates the storage for a directory path or an url:
+// file:///path, s3://bucket/prefix, gs://bucket/prefix or an http(s)
+// directory listing, which is read-only.
+func NewStorage(location string) (Storage, error) {
+	if !strings.Contains(location, "://") {
+		return DirStorage(location), nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return DirStorage(filepath.FromSlash(u.Path)), nil
+	case "s3", "gs":
+		s3, err := NewS3(u)
+		if err != nil {
+			return nil, err
+		}
+		return &S3Storage{s3}, nil
+	case "http", "https":
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		return &HTTPStorage{Root: u}, nil
+	}
+	return nil, fmt.Errorf("unsupported storage %q", location)
+}
+
+// DirStorage is a local directory.
+type DirStorage string
+
+// Local returns the local path of name.
+func (dir DirStorage) Local(name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%v: outside of %v", name, string(dir))
+	}
+	return filepath.Join(string(dir), local), nil
+}
+
+// List returns the files in the directory, a missing directory is empty.
+func (dir DirStorage) List() ([]StorageFile, error) {
+	var files []StorageFile
+	err := filepath.Walk(string(dir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(string(dir), path)
+		if err != nil {
+			return err
+		}
+		files = append(files, StorageFile{
+			Name:     filepath.ToSlash(rel),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return files, err
+}
+
+// Open opens the file.
+func (dir DirStorage) Open(name string, since time.Time) (io.ReadCloser, time.Time, error) {
+	path, err := dir.Local(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !since.IsZero() && !info.ModTime().After(since) {
+		return nil, time.Time{}, ErrNotModified
+	}
+	file, err := os.Open(path)
+	return file, info.ModTime(), err
+}
+
+// Put writes the file atomically.
+func (dir DirStorage) Put(name string, data []byte) error {
+	path, err := dir.Local(name)
+	if err != nil {
+		return err
+	}
+	return imgproc.WriteFile(path, data)
+}
+
+// Delete removes the file.
+func (dir DirStorage) Delete(name string) error {
+	path, err := dir.Local(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// MemStorage keeps the files in memory, it's used for testing.
+type MemStorage struct {
+	mu    sync.Mutex
+	files map[string]memFile
+}
+
+type memFile struct {
+	data     []byte
+	modified time.Time
+}
+
+// NewMemStorage creates an empty storage.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{files: map[string]memFile{}}
+}
+
+// List returns the files.
+func (mem *MemStorage) List() ([]StorageFile, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	var files []StorageFile
+	for name, file := range mem.files {
+		files = append(files, StorageFile{Name: name, Size: int64(len(file.data)), Modified: file.modified})
+	}
+	sort.Slice(files, func(i, k int) bool { return files[i].Name < files[k].Name })
+	return files, nil
+}
+
+// Open reads the file.
+func (mem *MemStorage) Open(name string, since time.Time) (io.ReadCloser, time.Time, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	file, ok := mem.files[name]
+	if !ok {
+		return nil, time.Time{}, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if !since.IsZero() && !file.modified.After(since) {
+		return nil, time.Time{}, ErrNotModified
+	}
+	return ioutil.NopCloser(bytes.NewReader(file.data)), file.modified, nil
+}
+
+// Put writes the file.
+func (mem *MemStorage) Put(name string, data []byte) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	mem.files[name] = memFile{data: append([]byte{}, data...), modified: time.Now()}
+	return nil
+}
+
+// Delete removes the file.
+func (mem *MemStorage) Delete(name string) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	delete(mem.files, name)
+	return nil
+}
+
+// storageInfo describes a file or a directory of a storage.
+type storageInfo struct {
+	file StorageFile
+	dir  bool
+}
+
+func (info storageInfo) Name() string       { return path.Base(info.file.Name) }
+func (info storageInfo) Size() int64        { return info.file.Size }
+func (info storageInfo) ModTime() time.Time { return info.file.Modified }
+func (info storageInfo) IsDir() bool        { return info.dir }
+func (info storageInfo) Sys() interface{}   { return nil }
+
+func (info storageInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// S3Storage is a bucket of an S3 compatible storage.
+type S3Storage struct{ *S3 }
+
+// List returns the objects under the prefix.
+func (storage *S3Storage) List() ([]StorageFile, error) {
+	objects, err := storage.S3.List()
+	if err != nil {
+		return nil, err
+	}
+	var files []StorageFile
+	for _, object := range objects {
+		name := object.Key
+		if storage.Prefix != "" {
+			name = strings.TrimPrefix(name, storage.Prefix+"/")
+		}
+		// directory markers created by web consoles
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		files = append(files, StorageFile{Name: name, Size: object.Size, Modified: object.LastModified})
+	}
+	return files, nil
+}
+
+// Open downloads the object.
+func (storage *S3Storage) Open(name string, since time.Time) (io.ReadCloser, time.Time, error) {
+	return storage.Get(storage.Key(name), since)
+}
+
+// Put uploads the object with the content type and caching of the name.
+func (storage *S3Storage) Put(name string, data []byte) error {
+	return storage.S3.Put(storage.Key(name), data, ContentType(name), CacheControl(name))
+}
+
+// Delete removes the object.
+func (storage *S3Storage) Delete(name string) error {
+	return storage.S3.Delete(storage.Key(name))
+}
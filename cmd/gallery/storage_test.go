@@ -0,0 +1,375 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+func TestMemStorage(t *testing.T) {
+	mem := NewMemStorage()
+	for name, data := range map[string]string{"b/c.txt": "c", "a.txt": "a"} {
+		if err := mem.Put(name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := mem.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	if want := []string{"a.txt", "b/c.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, expected %v", names, want)
+	}
+
+	body, modified, err := mem.Open("a.txt", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(body)
+	body.Close()
+	if string(data) != "a" {
+		t.Errorf("read %q, expected %q", data, "a")
+	}
+	if _, _, err := mem.Open("a.txt", modified); err != ErrNotModified {
+		t.Errorf("unchanged file opened: %v", err)
+	}
+
+	if err := mem.Delete("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := mem.Open("a.txt", time.Time{}); !os.IsNotExist(err) {
+		t.Errorf("deleted file opened: %v", err)
+	}
+}
+
+func TestStorageFilesWalk(t *testing.T) {
+	mem := NewMemStorage()
+	for _, name := range []string{"b/y.jpg", "a.jpg", "b/c/z.jpg", "b/x.jpg", "d/w.jpg"} {
+		mem.Put(name, []byte(name))
+	}
+	dir := t.TempDir()
+	files, err := NewStorageFiles(mem, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		skip string
+		want []string
+	}{
+		{"", []string{".", "a.jpg", "b", "b/c", "b/c/z.jpg", "b/x.jpg", "b/y.jpg", "d", "d/w.jpg"}},
+		{"b/c", []string{".", "a.jpg", "b", "b/c", "b/x.jpg", "b/y.jpg", "d", "d/w.jpg"}},
+		{"b/x.jpg", []string{".", "a.jpg", "b", "b/c", "b/c/z.jpg", "b/x.jpg", "d", "d/w.jpg"}},
+	}
+	for _, test := range tests {
+		var walked []string
+		err := files.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(dir, path)
+			rel = filepath.ToSlash(rel)
+			walked = append(walked, rel)
+			if info.IsDir() != (filepath.Ext(rel) == "" && rel != "") && rel != "." {
+				t.Errorf("%v: directory is %v", rel, info.IsDir())
+			}
+			if rel == test.skip {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(walked, test.want) {
+			t.Errorf("skipping %q walked %v, expected %v", test.skip, walked, test.want)
+		}
+	}
+}
+
+// countingStorage counts the files opened from the storage.
+type countingStorage struct {
+	Storage
+	mu     sync.Mutex
+	opened map[string]int
+}
+
+func (storage *countingStorage) Open(name string, since time.Time) (io.ReadCloser, time.Time, error) {
+	storage.mu.Lock()
+	storage.opened[name]++
+	storage.mu.Unlock()
+	return storage.Storage.Open(name, since)
+}
+
+func TestStorageFilesFetch(t *testing.T) {
+	dir := t.TempDir()
+	source := &countingStorage{Storage: NewMemStorage(), opened: map[string]int{}}
+	for _, name := range []string{"a.jpg", "b/c.jpg", "b/d.jpg"} {
+		source.Put(name, []byte(name))
+	}
+
+	fetch := func() map[string]int {
+		t.Helper()
+		source.opened = map[string]int{}
+		files, err := NewStorageFiles(source, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := files.Prune(); err != nil {
+			t.Fatal(err)
+		}
+		for name := range files.files {
+			if err := files.Fetch(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return source.opened
+	}
+
+	if opened := fetch(); len(opened) != 3 {
+		t.Errorf("first fetch downloaded %v", opened)
+	}
+	if opened := fetch(); len(opened) != 0 {
+		t.Errorf("unchanged files downloaded %v", opened)
+	}
+
+	source.Put("b/c.jpg", []byte("changed"))
+	source.Delete("b/d.jpg")
+	if opened, want := fetch(), map[string]int{"b/c.jpg": 1}; !reflect.DeepEqual(opened, want) {
+		t.Errorf("downloaded %v, expected %v", opened, want)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "b", "c.jpg")); err != nil || string(data) != "changed" {
+		t.Errorf("changed file not downloaded: %q %v", data, err)
+	}
+	if FileExists(filepath.Join(dir, "b", "d.jpg")) {
+		t.Errorf("removed file kept in the cache")
+	}
+}
+
+func TestStorageFilesHTTP(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpg", filepath.Join("b", "c.jpg")} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lastModified := range []bool{true, false} {
+		var mu sync.Mutex
+		requests := map[string]int{}
+		files := http.FileServer(http.Dir(root))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				mu.Lock()
+				requests[r.Method+" "+r.URL.Path]++
+				mu.Unlock()
+			}
+			if !lastModified && !strings.HasSuffix(r.URL.Path, "/") {
+				// the content is served without Last-Modified for a zero time
+				f, err := os.Open(filepath.Join(root, filepath.FromSlash(r.URL.Path)))
+				if err != nil {
+					http.NotFound(w, r)
+					return
+				}
+				defer f.Close()
+				http.ServeContent(w, r, "", time.Time{}, f)
+				return
+			}
+			files.ServeHTTP(w, r)
+		}))
+
+		storage, err := NewStorage(server.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		source, err := NewStorageFiles(storage, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]int{"HEAD /a.jpg": 1, "HEAD /b/c.jpg": 1}; !reflect.DeepEqual(requests, want) {
+			t.Errorf("last modified %v: listing requested %v, expected %v", lastModified, requests, want)
+		}
+		if FileExists(filepath.Join(dir, "a.jpg")) {
+			t.Errorf("last modified %v: listing downloaded the files", lastModified)
+		}
+
+		path := filepath.Join(dir, "b", "c.jpg")
+		info, err := source.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(filepath.Join("b", "c.jpg"))) || info.ModTime().IsZero() != !lastModified {
+			t.Errorf("last modified %v: described as %v %v", lastModified, info.Size(), info.ModTime())
+		}
+
+		requests = map[string]int{}
+		if err := source.Fetch(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := source.Fetch(path); err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]int{"GET /b/c.jpg": 1}; !reflect.DeepEqual(requests, want) {
+			t.Errorf("last modified %v: fetching requested %v, expected %v", lastModified, requests, want)
+		}
+		if info, err := source.Stat(path); err != nil || info.ModTime().IsZero() {
+			t.Errorf("last modified %v: fetched file not described: %v", lastModified, err)
+		}
+		server.Close()
+	}
+}
+
+// useStorage builds the site from the source storage into the output storage.
+func useStorage(t *testing.T, source Storage, cache string, output Storage) {
+	t.Helper()
+	files, err := NewStorageFiles(source, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gallery.Files, site = files, output
+	t.Cleanup(func() { gallery.Files, site = gallery.LocalFiles{}, nil })
+}
+
+func TestBuildStorage(t *testing.T) {
+	dir := t.TempDir()
+	source := &countingStorage{Storage: NewMemStorage(), opened: map[string]int{}}
+	for _, name := range []string{"summer/a.png", "summer/b.png", "winter/c.png"} {
+		if err := source.Put(name, testImage(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := source.Put("summer/"+gallery.ConfigName, []byte("title: Hot Summer\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewMemStorage()
+	useStorage(t, source, filepath.Join(dir, "images"), output)
+	setFlags(t, map[string]string{
+		"source": filepath.Join(dir, "images"),
+		"output": filepath.Join(dir, "public"),
+		"quiet":  "true",
+	})
+	if err := ValidateFlags(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if T, err = LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Build(false); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "summer/index.html", "summer/a.html", "winter/c.html",
+		"thumbs/summer/a.png", "images/winter/c.png", ManifestName} {
+		if _, _, err := output.Open(name, time.Time{}); err != nil {
+			t.Errorf("%v not generated: %v", name, err)
+		}
+	}
+	if page, _, err := output.Open("summer/index.html", time.Time{}); err == nil {
+		data, _ := ioutil.ReadAll(page)
+		if !strings.Contains(string(data), "Hot Summer") {
+			t.Errorf("gallery configuration not applied")
+		}
+	}
+	if FileExists(filepath.Join(dir, "public")) {
+		t.Errorf("site written into the output directory")
+	}
+
+	// the originals are read again only when their outputs are stale
+	source.opened = map[string]int{}
+	if err := Build(false); err != nil {
+		t.Fatal(err)
+	}
+	for name := range source.opened {
+		if gallery.IsSource(name) {
+			t.Errorf("%v read from the storage again", name)
+		}
+	}
+}
+
+func TestBuildStorageChanges(t *testing.T) {
+	dir := t.TempDir()
+	source := NewMemStorage()
+	for _, name := range []string{"trip/a.png", "trip/b.png"} {
+		if err := source.Put(name, testImage(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source.Put("trip/"+gallery.ConfigName, []byte("title: Road Trip\n"))
+
+	output := NewMemStorage()
+	setFlags(t, map[string]string{
+		"source": filepath.Join(dir, "images"),
+		"output": filepath.Join(dir, "public"),
+		"zip":    "large",
+		"clean":  "true",
+		"quiet":  "true",
+	})
+	if err := ValidateFlags(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if T, err = LoadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		body, _, err := output.Open(name, time.Time{})
+		if err != nil {
+			t.Errorf("%v not generated: %v", name, err)
+			return ""
+		}
+		defer body.Close()
+		data, _ := ioutil.ReadAll(body)
+		return string(data)
+	}
+
+	useStorage(t, source, filepath.Join(dir, "images"), output)
+	if err := Build(false); err != nil {
+		t.Fatal(err)
+	}
+	read("trip/b.html")
+	read("images/trip/b.png")
+	if zip := read("trip/trip.zip"); !strings.HasPrefix(zip, "PK") {
+		t.Errorf("trip/trip.zip isn't a ZIP")
+	}
+
+	// the next build sees the changed source storage
+	source.Delete("trip/b.png")
+	source.Put("trip/"+gallery.ConfigName, []byte("title: Long Road Trip\n"))
+	useStorage(t, source, filepath.Join(dir, "images"), output)
+	if err := Build(false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(read("trip/index.html"), "Long Road Trip") {
+		t.Errorf("gallery page not updated")
+	}
+	for _, name := range []string{"trip/b.html", "images/trip/b.png", "thumbs/trip/b.png"} {
+		if _, _, err := output.Open(name, time.Time{}); !os.IsNotExist(err) {
+			t.Errorf("%v of the removed image kept: %v", name, err)
+		}
+	}
+	read("images/trip/a.png")
+	if FileExists(filepath.Join(dir, "public")) {
+		t.Errorf("site written into the output directory")
+	}
+}
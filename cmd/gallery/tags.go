@@ -0,0 +1,22 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/egonelbre/gallery"
+)
+
+// CreateTagPages creates a page for every tag and the tag cloud page.
+func CreateTagPages(tags []*gallery.Tag) {
+	for _, tag := range tags {
+		CreatePage(filepath.Join("tags", tag.Slug, "index.html"), "tag.html", map[string]interface{}{
+			"Title": tag.Name,
+			"Tag":   tag,
+		})
+	}
+
+	CreatePage(filepath.Join("tags", "index.html"), "tags.html", map[string]interface{}{
+		"Title": "Tags",
+		"Tags":  gallery.TagCloud(tags),
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var takeoutyears = ImportFlags.Bool("takeout-years", false, "also import the \"Photos from YYYY\" folders of a Google Takeout, not only the albums")
+
+// takeoutMetadata is a JSON sidecar of a Google Photos Takeout,
+// it describes either a photo or an album.
+type takeoutMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Date is when the album was created.
+	Date *takeoutTime `json:"date"`
+	// PhotoTakenTime is set only for photos.
+	PhotoTakenTime *takeoutTime `json:"photoTakenTime"`
+}
+
+// takeoutTime is a timestamp of a Takeout sidecar.
+type takeoutTime struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// Time returns the timestamp, zero when it's missing.
+func (t *takeoutTime) Time() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseInt(t.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// takeoutFolder is a folder of a Takeout, large exports are split into
+// several archives and the files of a folder may be in any of them.
+type takeoutFolder struct {
+	Name string
+	// Album is nil for the folders that group the photos by year.
+	Album  *takeoutMetadata
+	Photos []takeoutPhoto
+
+	byName  map[string]*takeoutMetadata
+	byTitle map[string]*takeoutMetadata
+}
+
+// takeoutPhoto is a photo in one of the archives.
+type takeoutPhoto struct {
+	Archive fs.FS
+	Path    string
+}
+
+// metadata returns the sidecar of the photo, edited copies use the
+// sidecar of the original.
+func (folder *takeoutFolder) metadata(name string) *takeoutMetadata {
+	for _, name := range []string{name, strings.Replace(name, "-edited", "", 1)} {
+		if meta, ok := folder.byName[name]; ok {
+			return meta
+		}
+		if meta, ok := folder.byTitle[name]; ok {
+			return meta
+		}
+	}
+	return &takeoutMetadata{}
+}
+
+// takeoutSidecarOf returns the name of the file the JSON sidecar belongs to.
+// Newer exports name them IMG_1234.jpg.supplemental-metadata.json and
+// truncate long names, the photo title is used for the rest.
+func takeoutSidecarOf(name string) string {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	if i := strings.LastIndex(base, "."); i >= 0 && i+1 < len(base) && strings.HasPrefix("supplemental-metadata", base[i+1:]) {
+		return base[:i]
+	}
+	return base
+}
+
+// ImportTakeout imports a Google Photos Takeout, each album becomes a gallery
+// with the album title and description and the photos get sidecars with
+// their description and the time they were taken. The galleries are
+// imported as private, so they can be reviewed before publishing.
+func ImportTakeout(dest string, exports []string) error {
+	archives, closeArchives, err := openArchives(exports)
+	if err != nil {
+		return err
+	}
+	defer closeArchives()
+
+	folders := map[string]*takeoutFolder{}
+	folderOf := func(dir string) *takeoutFolder {
+		folder, ok := folders[dir]
+		if !ok {
+			folder = &takeoutFolder{
+				Name:    path.Base(dir),
+				byName:  map[string]*takeoutMetadata{},
+				byTitle: map[string]*takeoutMetadata{},
+			}
+			folders[dir] = folder
+		}
+		return folder
+	}
+
+	for _, archive := range archives {
+		err := fs.WalkDir(archive, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if name != "." && gallery.IsIgnored(entry.Name()) {
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				return nil
+			}
+
+			folder := folderOf(path.Dir(name))
+			switch {
+			case strings.EqualFold(path.Ext(name), ".json"):
+				data, err := fs.ReadFile(archive, name)
+				if err != nil {
+					return err
+				}
+				meta := &takeoutMetadata{}
+				if err := json.Unmarshal(data, meta); err != nil {
+					slog.Warn("invalid sidecar", "file", name, "error", err)
+					return nil
+				}
+				if meta.PhotoTakenTime == nil {
+					if meta.Title != "" {
+						folder.Album = meta
+					}
+					return nil
+				}
+				folder.byName[takeoutSidecarOf(entry.Name())] = meta
+				if meta.Title != "" {
+					folder.byTitle[meta.Title] = meta
+				}
+			case gallery.IsSource(name):
+				folder.Photos = append(folder.Photos, takeoutPhoto{Archive: archive, Path: name})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// albums are imported first, the photos in albums are skipped
+	// in the year folders
+	var dirs []string
+	for dir := range folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, k int) bool {
+		a, b := folders[dirs[i]], folders[dirs[k]]
+		if (a.Album == nil) != (b.Album == nil) {
+			return a.Album != nil
+		}
+		return dirs[i] < dirs[k]
+	})
+
+	imported := map[string]bool{}
+	for _, dir := range dirs {
+		folder := folders[dir]
+		if len(folder.Photos) == 0 {
+			continue
+		}
+		if folder.Album == nil && !*takeoutyears {
+			slog.Info("skipping folder without an album", "folder", dir, "photos", len(folder.Photos))
+			continue
+		}
+
+		config := ImportedGallery{
+			Title:      folder.Name,
+			Visibility: gallery.VisibilityPrivate,
+		}
+		var date, earliest time.Time
+		if folder.Album != nil {
+			config.Title = folder.Album.Title
+			config.Description = folder.Album.Description
+			date = folder.Album.Date.Time()
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(folder.Name))
+		copied, skipped := 0, 0
+		for _, photo := range folder.Photos {
+			name := path.Base(photo.Path)
+			meta := folder.metadata(name)
+			taken := meta.PhotoTakenTime.Time()
+
+			key := name + "@" + strconv.FormatInt(taken.Unix(), 10)
+			if folder.Album == nil && imported[key] {
+				skipped++
+				continue
+			}
+			imported[key] = true
+
+			source := filepath.Join(target, name)
+			ok, err := importFile(photo.Archive, photo.Path, source, taken)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+			copied++
+
+			sidecar := gallery.Sidecar{Caption: strings.TrimSpace(meta.Description)}
+			if !taken.IsZero() {
+				sidecar.Date = taken.Local().Format(gallery.SidecarDate)
+				if earliest.IsZero() || taken.Before(earliest) {
+					earliest = taken
+				}
+			}
+			if err := writeSidecar(source, sidecar); err != nil {
+				return err
+			}
+		}
+
+		if copied == 0 {
+			slog.Info("album already imported", "folder", target, "skipped", skipped)
+			continue
+		}
+		if date.IsZero() {
+			date = earliest
+		}
+		if !date.IsZero() {
+			config.Date = date.Local().Format("2006-01-02")
+		}
+		if err := writeGalleryConfig(target, config); err != nil {
+			return err
+		}
+		slog.Info("album imported", "folder", target, "photos", copied, "skipped", skipped)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/egonelbre/gallery/render"
+)
+
+var templatesdir = BuildFlags.String("templates", ".", "directory with templates overriding the default theme")
+
+// LoadTemplates parses the default theme and then the templates
+// in the templates directory, which replace the same-named ones.
+func LoadTemplates() (*template.Template, error) {
+	return render.Templates(*templatesdir, template.FuncMap{
+		"asset":     AssetLink,
+		"pwa":       PWAEnabled,
+		"lightbox":  LightboxEnabled,
+		"analytics": AnalyticsSnippet,
+	})
+}
+
+// defaultSiteConfig is the configuration file written by init.
+const defaultSiteConfig = `# Settings use the flag names, see "gallery -help" for all of them.
+source: images
+output: public
+sizes: [256, 1024]
+# base-url: https://example.com
+# webp: true
+# analytics: <script defer data-domain="example.com" src="https://plausible.io/js/script.js"></script>
+`
+
+// InitCommand creates the directory layout, the default theme
+// and a configuration file in the directory, existing files are kept.
+func InitCommand(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: init [directory]")
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "images"), 0755); err != nil {
+		return err
+	}
+
+	err := fs.WalkDir(render.Theme, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(render.Theme, path)
+		if err != nil {
+			return err
+		}
+		return writeNew(filepath.Join(dir, filepath.FromSlash(path)), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeNew(filepath.Join(dir, SiteConfigNames[0]), []byte(defaultSiteConfig))
+}
+
+// writeNew writes the file unless it already exists.
+func writeNew(path string, data []byte) error {
+	if FileExists(path) {
+		fmt.Println("Keeping ", path)
+		return nil
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	fmt.Println("Creating ", path)
+	return ioutil.WriteFile(path, data, 0644)
+}
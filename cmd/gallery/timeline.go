@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var timelinechunk = BuildFlags.Int("timeline-chunk", 100, "number of images in a timeline chunk")
+
+// TimelineEntry is an image in the timeline chunks.
+type TimelineEntry struct {
+	Title       string    `json:"title"`
+	Page        string    `json:"page"`
+	Thumb       string    `json:"thumb"`
+	ThumbWebP   string    `json:"thumbWebP,omitempty"`
+	Date        time.Time `json:"date"`
+	Gallery     string    `json:"gallery"`
+	GalleryLink string    `json:"galleryLink"`
+}
+
+// TimelineChunk is a part of the timeline stored as JSON.
+type TimelineChunk struct {
+	Entries []TimelineEntry `json:"entries"`
+	// Next is the link to the following chunk, empty for the last chunk.
+	Next string `json:"next,omitempty"`
+}
+
+// TimelineChunkLink returns the link to the n-th chunk of the timeline.
+func TimelineChunkLink(n int) string {
+	return "/timeline/" + strconv.Itoa(n) + ".json"
+}
+
+// CreateTimeline writes the timeline page and the JSON chunks
+// of all images ordered by capture date.
+func CreateTimeline(galleries map[string]*gallery.Gallery) error {
+	var entries []TimelineEntry
+	for _, g := range galleries {
+		for _, image := range g.Images {
+			entry := TimelineEntry{
+				Title:       image.Title,
+				Page:        image.PageLink(),
+				Thumb:       image.ThumbLink(),
+				Date:        image.Date(),
+				Gallery:     g.Title,
+				GalleryLink: g.PageLink() + "/",
+			}
+			if image.ThumbWebP != "" {
+				entry.ThumbWebP = image.ThumbWebPLink()
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, k int) bool {
+		if entries[i].Date.Equal(entries[k].Date) {
+			return entries[i].Page < entries[k].Page
+		}
+		return entries[i].Date.After(entries[k].Date)
+	})
+
+	size := *timelinechunk
+	if size <= 0 {
+		size = len(entries) + 1
+	}
+
+	dir := Output("timeline")
+
+	count := (len(entries) + size - 1) / size
+	if count == 0 {
+		count = 1
+	}
+
+	var first TimelineChunk
+	for i := 0; i < count; i++ {
+		low, high := i*size, (i+1)*size
+		if high > len(entries) {
+			high = len(entries)
+		}
+
+		chunk := TimelineChunk{Entries: entries[low:high]}
+		if i+1 < count {
+			chunk.Next = TimelineChunkLink(i + 2)
+		}
+		if i == 0 {
+			first = chunk
+		}
+
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		if err := WriteOutput(filepath.Join(dir, strconv.Itoa(i+1)+".json"), data); err != nil {
+			return err
+		}
+	}
+
+	CreatePage(filepath.Join("timeline", "index.html"), "timeline.html", map[string]interface{}{
+		"Title":    "Timeline",
+		"Timeline": first,
+	})
+	return nil
+}
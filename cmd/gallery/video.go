@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/egonelbre/gallery"
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+var (
+	transcode = BuildFlags.Bool("transcode", false, "transcode videos to H.264 MP4 instead of copying them")
+	previews  = BuildFlags.Bool("video-previews", false, "generate animated hover previews for videos")
+)
+
+// processVideo publishes the video and extracts a poster frame for the
+// thumbnail and the video page.
+func processVideo(image *gallery.Image) (skipped bool, err error) {
+	var errs Errors
+	stale := false
+
+	thumbname := Output(image.Thumb)
+	imagename := Output(image.Path)
+	postername := Output(image.Poster)
+	previewname := Output(image.Preview)
+	thumbwebp := Output(image.ThumbWebP)
+
+	videosettings := Settings("video")
+	if !manifest.Fresh(imagename, image.Raw, videosettings) {
+		stale = true
+		err := WriteOutputFile(imagename, func(file string) error {
+			if err := gallery.Files.Fetch(image.Raw); err != nil {
+				return err
+			}
+			if *transcode {
+				return imgproc.TranscodeVideo(image.Raw, file, StripsMetadata())
+			}
+			return imgproc.CopyVideo(image.Raw, file, StripsMetadata())
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(imagename, image.Raw, videosettings)
+		}
+	}
+
+	previewsettings := Settings("preview", sizes.Thumb())
+	if image.Preview != "" && !manifest.Fresh(previewname, image.Raw, previewsettings) {
+		stale = true
+		err := WriteOutputFile(previewname, func(file string) error {
+			if err := gallery.Files.Fetch(image.Raw); err != nil {
+				return err
+			}
+			return imgproc.VideoPreview(image.Raw, file, sizes.Thumb())
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(previewname, image.Raw, previewsettings)
+		}
+	}
+
+	thumbsettings := Settings("thumb", image.ThumbFormat)
+	thumbwebpsettings := Settings("thumb", "webp")
+	postersettings := Settings("poster", sizes.Large())
+	posterDone := manifest.Fresh(thumbname, image.Raw, thumbsettings) &&
+		manifest.Fresh(postername, image.Raw, postersettings) &&
+		(image.ThumbWebP == "" || manifest.Fresh(thumbwebp, image.Raw, thumbwebpsettings))
+	if posterDone {
+		return !stale, errs.Err()
+	}
+
+	if err := gallery.Files.Fetch(image.Raw); err != nil {
+		errs.Add(err)
+		return false, errs.Err()
+	}
+	frame, err := imgproc.VideoFrame(image.Raw)
+	if err != nil {
+		errs.Add(err)
+		return false, errs.Err()
+	}
+
+	thumb := Thumbnail(frame)
+	SetPlaceholders(image, thumb)
+	if !manifest.Fresh(thumbname, image.Raw, thumbsettings) {
+		stale = true
+		err := WriteOutputFile(thumbname, func(file string) error {
+			return imgproc.Save(thumb, file, image.ThumbFormat, JPEGQuality(sizes.Thumb()))
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(thumbname, image.Raw, thumbsettings)
+		}
+	}
+	if image.ThumbWebP != "" && !manifest.Fresh(thumbwebp, image.Raw, thumbwebpsettings) {
+		stale = true
+		err := WriteOutputFile(thumbwebp, func(file string) error {
+			return imgproc.SaveWebP(thumb, file, imgproc.WebPQuality)
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(thumbwebp, image.Raw, thumbwebpsettings)
+		}
+	}
+
+	poster := imgproc.Downscale(frame, sizes.Large())
+	if !manifest.Fresh(postername, image.Raw, postersettings) {
+		stale = true
+		err := WriteOutputFile(postername, func(file string) error {
+			return imgproc.Save(poster, file, *largeformat, JPEGQuality(sizes.Large()))
+		})
+		if err != nil {
+			errs.Add(err)
+		} else {
+			manifest.Record(postername, image.Raw, postersettings)
+		}
+	}
+	return !stale, errs.Err()
+}
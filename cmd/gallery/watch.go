@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var watch = BuildFlags.Bool("watch", false, "rebuild when images, templates or static files change")
+
+// watchDelay is how long to wait for further changes before rebuilding.
+const watchDelay = 300 * time.Millisecond
+
+// Watch rebuilds the site when the sources change.
+//
+// Image changes regenerate the galleries containing them, their parents
+// and the site-wide pages; removing files or directories rebuilds
+// everything, so -clean can remove their outputs. Template changes only
+// regenerate pages and changes to static files only copy them.
+func Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watchTree(watcher, *sourcedir); err != nil {
+		return err
+	}
+	for _, dir := range StaticDirs() {
+		if !FileExists(dir) {
+			continue
+		}
+		if err := watchTree(watcher, dir); err != nil {
+			return err
+		}
+	}
+	if err := watcher.Add(*templatesdir); err != nil {
+		return err
+	}
+
+	slog.Info("watching for changes")
+
+	var images, removed, templates, static bool
+	var changed []string
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+
+			name := filepath.Clean(event.Name)
+			switch {
+			case isUnder(name, filepath.Clean(*sourcedir)):
+				images = true
+				if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					removed = true
+				}
+				// the directory of a file, or a directory itself, is a gallery
+				dir := filepath.Dir(name)
+				if info, err := os.Stat(name); err == nil && info.IsDir() {
+					dir = name
+					if event.Has(fsnotify.Create) {
+						if err := watchTree(watcher, name); err != nil {
+							slog.Error("watch failed", "dir", name, "err", err)
+						}
+					}
+				}
+				changed = append(changed, dir)
+			case isStatic(name):
+				static = true
+			case filepath.Dir(name) == filepath.Clean(*templatesdir) && filepath.Ext(name) == ".html":
+				templates = true
+			default:
+				continue
+			}
+			timer.Reset(watchDelay)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("watch failed", "err", err)
+
+		case <-timer.C:
+			// static files change the fingerprinted links, so pages are rebuilt too
+			if images || templates || static {
+				if templates {
+					t, err := LoadTemplates()
+					if err != nil {
+						slog.Error("loading templates failed", "err", err)
+						break
+					}
+					T = t
+				}
+				// pages link to the templates and static files of every gallery
+				if removed || templates || static {
+					changed = nil
+				}
+				start := time.Now()
+				if err := BuildChanged(!images, changed); err != nil {
+					slog.Error("build failed", "err", err)
+				}
+				slog.Info("rebuilt", "duration", time.Since(start).Round(time.Millisecond))
+				reloader.Notify()
+			}
+			images, removed, templates, static = false, false, false, false
+			changed = nil
+		}
+	}
+}
+
+// watchTree adds dir and all its subdirectories to the watcher.
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// isStatic returns whether path is in one of the static directories.
+func isStatic(path string) bool {
+	for _, dir := range StaticDirs() {
+		if isUnder(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnder returns whether path is dir or inside dir.
+func isUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+var waypoints = BuildFlags.Bool("waypoints", false, "create GPX and KML files of geotagged images for each gallery, requires -gps-privacy=false")
+
+// WaypointsEnabled returns whether GPX and KML files are created.
+func WaypointsEnabled() bool { return *waypoints && !*gpsprivacy }
+
+// GPX is a GPX 1.1 document with waypoints.
+type GPX struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Name      string        `xml:"metadata>name"`
+	Waypoints []GPXWaypoint `xml:"wpt"`
+}
+
+type GPXWaypoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Time      *xmlTime `xml:"time,omitempty"`
+	Name      string   `xml:"name"`
+	Link      *GPXLink `xml:"link,omitempty"`
+}
+
+type GPXLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text,omitempty"`
+}
+
+// KML is a KML 2.2 document with placemarks.
+type KML struct {
+	XMLName    xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []KMLPlacemark `xml:"Document>Placemark"`
+}
+
+type KMLPlacemark struct {
+	Name        string   `xml:"name"`
+	Description string   `xml:"description,omitempty"`
+	TimeStamp   *xmlTime `xml:"TimeStamp>when,omitempty"`
+	Coordinates string   `xml:"Point>coordinates"`
+}
+
+// xmlTime formats time as RFC3339 in UTC.
+type xmlTime struct{ time.Time }
+
+func (t xmlTime) MarshalText() ([]byte, error) {
+	return []byte(t.UTC().Format(time.RFC3339)), nil
+}
+
+// CreateWaypoints writes photos.gpx and photos.kml for the gallery,
+// it returns false when the gallery doesn't contain geotagged images.
+func CreateWaypoints(g *gallery.Gallery) (bool, error) {
+	located := LocatedImages(g)
+	if len(located) == 0 {
+		return false, nil
+	}
+	sort.SliceStable(located, func(i, k int) bool {
+		return located[i].Date().Before(located[k].Date())
+	})
+
+	gpx := GPX{Version: "1.1", Creator: "gallery", Name: g.Title}
+	kml := KML{Name: g.Title}
+	for _, image := range located {
+		location := image.Location()
+		var taken *xmlTime
+		if !image.Metadata.Taken.IsZero() {
+			taken = &xmlTime{image.Metadata.Taken}
+		}
+
+		gpx.Waypoints = append(gpx.Waypoints, GPXWaypoint{
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+			Time:      taken,
+			Name:      image.Title,
+			Link:      &GPXLink{Href: AbsoluteURL(image.PageLink()), Text: image.Title},
+		})
+		kml.Placemarks = append(kml.Placemarks, KMLPlacemark{
+			Name:        image.Title,
+			Description: AbsoluteURL(image.PageLink()),
+			TimeStamp:   taken,
+			Coordinates: formatFloat(location.Longitude) + "," + formatFloat(location.Latitude),
+		})
+	}
+
+	dir := Output(g.Unbound)
+	if err := writeXML(filepath.Join(dir, "photos.gpx"), gpx); err != nil {
+		return true, err
+	}
+	return true, writeXML(filepath.Join(dir, "photos.kml"), kml)
+}
+
+func writeXML(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return WriteOutput(path, append([]byte(xml.Header), data...))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
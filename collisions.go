@@ -0,0 +1,54 @@
+package gallery
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// reservedNames are the output names used by the gallery pages.
+var reservedNames = []string{"index"}
+
+// outputKey returns the name the outputs of an unbound path are based on,
+// outputs of images with the same key overwrite each other.
+func outputKey(unbound string) string {
+	return strings.ToLower(ReplaceExt(unbound, ""))
+}
+
+// Disambiguate renames images whose outputs would overwrite each other,
+// e.g. "a.jpg" and "a.png", by appending the source extension to the
+// name, "a-png.png". The first image by source path keeps its name.
+// The renamed images are returned as problems.
+func Disambiguate(gallery *Gallery) []error {
+	images := append([]*Image{}, gallery.Images...)
+	sort.SliceStable(images, func(i, k int) bool {
+		return images[i].Raw < images[k].Raw
+	})
+
+	taken := map[string]bool{}
+	for _, name := range reservedNames {
+		taken[outputKey(filepath.Join(gallery.Unbound, name))] = true
+	}
+
+	var problems []error
+	for _, image := range images {
+		if !taken[outputKey(image.Unbound)] {
+			taken[outputKey(image.Unbound)] = true
+			continue
+		}
+
+		ext := filepath.Ext(image.Unbound)
+		base := ReplaceExt(image.Unbound, "") + "-" + strings.ToLower(strings.TrimPrefix(ext, "."))
+		unbound := base + ext
+		for i := 2; taken[outputKey(unbound)]; i++ {
+			unbound = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		taken[outputKey(unbound)] = true
+
+		problems = append(problems, fmt.Errorf("%v: output name is already used, publishing as %q", image.Raw, filepath.Base(unbound)))
+		image.Unbound = unbound
+		image.Path = filepath.Join(ImagesDir, unbound)
+	}
+	return problems
+}
@@ -0,0 +1,44 @@
+package gallery
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDisambiguate(t *testing.T) {
+	tests := []struct {
+		name     string
+		images   []string
+		want     []string
+		problems int
+	}{
+		{"distinct", []string{"a.jpg", "b.jpg"}, []string{"a.jpg", "b.jpg"}, 0},
+		{"extension", []string{"a.png", "a.jpg"}, []string{"a-png.png", "a.jpg"}, 1},
+		{"case", []string{"a.jpg", "A.jpg"}, []string{"a-jpg.jpg", "A.jpg"}, 1},
+		{"reserved", []string{"index.jpg"}, []string{"index-jpg.jpg"}, 1},
+		{"renamed taken", []string{"a.png", "a.jpg", "a-png.jpg"}, []string{"a-png-2.png", "a.jpg", "a-png.jpg"}, 1},
+		{"three", []string{"a.png", "a.jpg", "a.gif"}, []string{"a-png.png", "a-jpg.jpg", "a.gif"}, 2},
+	}
+	for _, test := range tests {
+		g := &Gallery{Unbound: "trip"}
+		for _, name := range test.images {
+			unbound := filepath.Join("trip", name)
+			g.Images = append(g.Images, &Image{
+				Raw:     filepath.Join("images", "trip", name),
+				Unbound: unbound,
+				Path:    filepath.Join(ImagesDir, unbound),
+			})
+		}
+
+		problems := Disambiguate(g)
+		if len(problems) != test.problems {
+			t.Errorf("%v: %d problems, expected %d: %v", test.name, len(problems), test.problems, problems)
+		}
+		for i, image := range g.Images {
+			want := filepath.Join("trip", test.want[i])
+			if image.Unbound != want || image.Path != filepath.Join(ImagesDir, want) {
+				t.Errorf("%v: %v published as %v %v, expected %v", test.name, image.Raw, image.Unbound, image.Path, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package gallery
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery/imgproc"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigName is the name of the per gallery configuration file.
+const ConfigName = "gallery.yaml"
+
+// Config contains the settings from gallery.yaml
+// or from the front matter of index.md.
+type Config struct {
+	Title string `yaml:"title"`
+	// Description is written in markdown.
+	Description string `yaml:"description"`
+	// Date is formatted as 2006-01-02.
+	Date string `yaml:"date"`
+	// Cover is the filename of the image used as the gallery cover.
+	Cover string `yaml:"cover"`
+	// Visibility is "public", "private" or "unlisted",
+	// private galleries are not published and unlisted galleries
+	// are published under a token, without linking to them.
+	Visibility string `yaml:"visibility"`
+	// Token replaces the generated token of an unlisted gallery.
+	Token string `yaml:"token"`
+	// Draft galleries are only published with Drafts,
+	// directories starting with "_" are drafts as well.
+	Draft bool `yaml:"draft"`
+
+	// Hidden lists the file names of the images that aren't published,
+	// with or without the extension.
+	Hidden []string `yaml:"hidden"`
+
+	// Sort overrides the default sort order for the gallery.
+	Sort string `yaml:"sort"`
+
+	// Originals overrides the default originals setting for the gallery
+	// and the nested galleries.
+	Originals *bool `yaml:"originals"`
+
+	// Weight orders the galleries with the weight gallery order,
+	// lighter galleries are listed first.
+	Weight int `yaml:"weight"`
+
+	// MinRating overrides the minimum star rating of the published images
+	// for the gallery and the nested galleries, 0 leaves out rejected
+	// images and -1 publishes all images.
+	MinRating *int `yaml:"min-rating"`
+
+	// Preset is the processing applied to the published photos of the
+	// gallery and the nested galleries: bw, sepia or contrast.
+	Preset string `yaml:"preset"`
+}
+
+// Visibility levels
+const (
+	VisibilityPublic   = "public"
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+)
+
+// DraftPrefix marks the gallery directories that are drafts.
+const DraftPrefix = "_"
+
+// Drafts enables publishing the draft galleries.
+var Drafts = false
+
+// LoadConfig loads gallery.yaml or index.md from dir, when it exists.
+//
+// When index.md is used, the YAML front matter contains the settings
+// and the rest of the file is used as the description.
+func LoadConfig(dir string) (Config, error) {
+	var config Config
+
+	path := filepath.Join(dir, ConfigName)
+	data, err := readFile(path)
+	if os.IsNotExist(err) {
+		path = filepath.Join(dir, "index.md")
+		data, err = readFile(path)
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		if err != nil {
+			return config, err
+		}
+
+		var body []byte
+		data, body = splitFrontMatter(data)
+		config.Description = strings.TrimSpace(string(body))
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("%v: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("%v: %v", path, err)
+	}
+	return config, nil
+}
+
+func (config *Config) validate() error {
+	if config.Sort != "" {
+		if err := ValidSortOrder(config.Sort); err != nil {
+			return err
+		}
+	}
+	if config.Date != "" {
+		if _, err := time.Parse("2006-01-02", config.Date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", config.Date)
+		}
+	}
+	switch config.Visibility {
+	case "", VisibilityPublic, VisibilityPrivate, VisibilityUnlisted:
+	default:
+		return fmt.Errorf("unknown visibility %q", config.Visibility)
+	}
+	if config.Token != "" && !validToken.MatchString(config.Token) {
+		return fmt.Errorf("invalid token %q, expected at least 16 letters, digits, - or _", config.Token)
+	}
+	if config.MinRating != nil && (*config.MinRating < -1 || *config.MinRating > 5) {
+		return fmt.Errorf("invalid min-rating %d, expected -1 to 5", *config.MinRating)
+	}
+	if _, ok := imgproc.Presets[config.Preset]; config.Preset != "" && !ok {
+		return fmt.Errorf("unknown preset %q, expected bw, sepia or contrast", config.Preset)
+	}
+	return nil
+}
+
+// splitFrontMatter splits a document into the YAML front matter
+// delimited by "---" lines and the body.
+func splitFrontMatter(data []byte) (front, body []byte) {
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		return nil, data
+	}
+
+	rest := data[len("---\n"):]
+	end := bytes.Index(rest, []byte("\n---"))
+	if end < 0 {
+		return nil, data
+	}
+
+	front = rest[:end+1]
+	body = rest[end+len("\n---"):]
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = nil
+	}
+	return front, body
+}
+
+// ApplyConfig sets the gallery fields from its configuration.
+func (gallery *Gallery) ApplyConfig(config Config) error {
+	gallery.Config = config
+
+	gallery.Title = strings.TrimPrefix(gallery.Name, DraftPrefix)
+	if config.Title != "" {
+		gallery.Title = config.Title
+	}
+	if config.Date != "" {
+		gallery.Date, _ = time.Parse("2006-01-02", config.Date)
+	}
+
+	description, err := RenderMarkdown(config.Description)
+	gallery.Description = description
+	return err
+}
+
+// Draft returns whether the gallery is configured as a draft
+// or its directory starts with DraftPrefix.
+func (gallery *Gallery) Draft() bool {
+	return gallery.Config.Draft || strings.HasPrefix(gallery.Name, DraftPrefix)
+}
+
+// Published returns whether the gallery should be part of the site.
+// Galleries nested in an unpublished gallery are not published either.
+func (gallery *Gallery) Published() bool {
+	if gallery.Config.Visibility == VisibilityPrivate {
+		return false
+	}
+	if gallery.Draft() && !Drafts {
+		return false
+	}
+	return gallery.Parent == nil || gallery.Parent.Published()
+}
@@ -0,0 +1,50 @@
+package gallery
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem reads the galleries. The paths are local paths under the
+// source directory, for a remote source they point into a local cache.
+type FileSystem interface {
+	// Walk calls fn for the files and directories in root like filepath.Walk.
+	Walk(root string, fn filepath.WalkFunc) error
+	// Stat returns the information of the file at path.
+	Stat(path string) (os.FileInfo, error)
+	// Open opens the file at path for reading.
+	Open(path string) (io.ReadCloser, error)
+	// Fetch makes the file available at path for the decoders and
+	// programs that only read local files.
+	Fetch(path string) error
+}
+
+// Files is the file system the galleries are read from.
+var Files FileSystem = LocalFiles{}
+
+// LocalFiles reads the galleries from the local file system.
+type LocalFiles struct{}
+
+// Walk walks root, following the symlinks with FollowSymlinks.
+func (LocalFiles) Walk(root string, fn filepath.WalkFunc) error { return walk(root, fn) }
+
+// Stat returns the information of the file at path.
+func (LocalFiles) Stat(path string) (os.FileInfo, error) { return os.Stat(path) }
+
+// Open opens the file at path.
+func (LocalFiles) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+// Fetch does nothing, the files are already local.
+func (LocalFiles) Fetch(path string) error { return nil }
+
+// readFile reads the whole file at path from Files.
+func readFile(path string) ([]byte, error) {
+	file, err := Files.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
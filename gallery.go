@@ -0,0 +1,513 @@
+// Package gallery finds the galleries and images in a source directory
+// and describes how they are published.
+package gallery
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery/imgproc"
+)
+
+// ImagesDir is the subdirectory of the output for published images.
+const ImagesDir = "images"
+
+type Gallery struct {
+	Name    string
+	Path    string
+	Unbound string
+	Images  []*Image
+
+	Title       string
+	Description template.HTML
+	Date        time.Time
+	// Cover is the image representing the gallery.
+	Cover *Image
+
+	// Parent is the gallery containing this gallery,
+	// Children are the galleries nested in this gallery.
+	Parent   *Gallery
+	Children []*Gallery
+
+	Config Config
+}
+
+// New creates a gallery for dir and loads its configuration.
+func New(imagesDir, dir string) (*Gallery, error) {
+	gallery := &Gallery{}
+	gallery.Name = filepath.Base(dir)
+	gallery.Path = dir
+	gallery.Unbound = strings.TrimPrefix(gallery.Path, imagesDir+string(filepath.Separator))
+	if gallery.Path == imagesDir {
+		gallery.Unbound = filepath.Base(imagesDir)
+	}
+	gallery.Unbound = SlugPath(gallery.Unbound)
+	config, err := LoadConfig(gallery.Path)
+	if err != nil {
+		return nil, err
+	}
+	if description, err := LoadDescription(gallery.Path); err != nil {
+		return nil, err
+	} else if description != "" {
+		config.Description = description
+	}
+	if err := gallery.ApplyConfig(config); err != nil {
+		return nil, fmt.Errorf("%v: %v", gallery.Path, err)
+	}
+	return gallery, nil
+}
+
+// Load finds the galleries in the source directory,
+// the unpublished galleries are returned separately.
+// Hidden images are skipped.
+//
+// With organize "date" the images are regrouped by capture date.
+func Load(source, organize string) (galleries map[string]*Gallery, unpublished []*Gallery, err error) {
+	galleries = map[string]*Gallery{}
+
+	imagesDir := filepath.Clean(source)
+
+	err = Files.Walk(imagesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != imagesDir && IsIgnored(info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if !IsSource(info.Name()) {
+			return nil
+		}
+
+		galleryPath := strings.ToLower(filepath.Dir(path))
+		gallery, ok := galleries[galleryPath]
+		if !ok {
+			gallery, err = New(imagesDir, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			galleries[galleryPath] = gallery
+		}
+		if gallery.Hides(path) {
+			return nil
+		}
+
+		unbound, err := filepath.Rel(imagesDir, path)
+		if err != nil {
+			return err
+		}
+		unbound = SlugFile(unbound)
+		gallery.Images = append(gallery.Images, &Image{
+			Name:    ReplaceExt(filepath.Base(path), ""),
+			Raw:     path,
+			Path:    filepath.Join(ImagesDir, unbound),
+			Unbound: unbound,
+			Info:    info,
+			Kind:    SourceKind(path),
+		})
+
+		return nil
+	})
+	if err == nil {
+		err = LinkGalleries(imagesDir, galleries)
+	}
+	if err == nil {
+		err = publishUnlisted(imagesDir, galleries)
+	}
+	if err == nil {
+		err = checkUnbound(galleries)
+	}
+
+	for key, gallery := range galleries {
+		if !gallery.Published() {
+			unpublished = append(unpublished, gallery)
+			delete(galleries, key)
+		}
+	}
+	if organize == "date" {
+		galleries = OrganizeByDate(imagesDir, galleries)
+	}
+
+	return galleries, unpublished, err
+}
+
+// checkUnbound returns an error when galleries are published to the same path,
+// e.g. when their names only differ by punctuation or diacritics.
+func checkUnbound(galleries map[string]*Gallery) error {
+	var sorted []*Gallery
+	for _, gallery := range galleries {
+		sorted = append(sorted, gallery)
+	}
+	sort.Slice(sorted, func(i, k int) bool { return sorted[i].Path < sorted[k].Path })
+
+	byUnbound := map[string]*Gallery{}
+	for _, gallery := range sorted {
+		key := strings.ToLower(gallery.Unbound)
+		if other, ok := byUnbound[key]; ok {
+			return fmt.Errorf("galleries %q and %q are both published as %q, rename one of them", other.Path, gallery.Path, gallery.Unbound)
+		}
+		byUnbound[key] = gallery
+	}
+	return nil
+}
+
+func (gallery *Gallery) PageLink() string {
+	return path.Join("/", filepath.ToSlash(gallery.Unbound))
+}
+
+// FindCover finds the cover image configured in the gallery metadata,
+// or named "cover", falling back to the first image.
+func FindCover(gallery *Gallery) *Image {
+	if len(gallery.Images) == 0 {
+		return nil
+	}
+	if name := gallery.Config.Cover; name != "" {
+		for _, image := range gallery.Images {
+			if image.MatchesName(name) {
+				return image
+			}
+		}
+	}
+	for _, image := range gallery.Images {
+		if image.MatchesName("cover") {
+			return image
+		}
+	}
+	return gallery.Images[0]
+}
+
+func (gallery *Gallery) FirstImages(n int) []*Image {
+	if n > len(gallery.Images) {
+		n = len(gallery.Images)
+	}
+	return gallery.Images[:n]
+}
+
+// ZipFile returns the output path of the gallery ZIP.
+func (gallery *Gallery) ZipFile() string {
+	return filepath.Join(gallery.Unbound, filepath.Base(gallery.Unbound)+".zip")
+}
+
+// ZipLink returns the link to the gallery ZIP.
+func (gallery *Gallery) ZipLink() string {
+	return path.Join("/", filepath.ToSlash(gallery.ZipFile()))
+}
+
+// PublishesOriginals returns whether the source files of the gallery are published,
+// the gallery setting takes precedence over the parent galleries and fallback.
+func (gallery *Gallery) PublishesOriginals(fallback bool) bool {
+	for g := gallery; g != nil; g = g.Parent {
+		if g.Config.Originals != nil {
+			return *g.Config.Originals
+		}
+	}
+	return fallback
+}
+
+// Preset returns the processing preset of the gallery,
+// the gallery setting takes precedence over the parent galleries.
+func (gallery *Gallery) Preset() string {
+	for g := gallery; g != nil; g = g.Parent {
+		if g.Config.Preset != "" {
+			return g.Config.Preset
+		}
+	}
+	return ""
+}
+
+// MinRating is the minimum star rating of the published images,
+// galleries can override it. By default all images are published,
+// 0 leaves out the rejected photos, which are rated -1.
+var MinRating = -1
+
+// MinimumRating returns the minimum star rating of the published images,
+// the gallery setting takes precedence over the parent galleries and MinRating.
+func (gallery *Gallery) MinimumRating() int {
+	for g := gallery; g != nil; g = g.Parent {
+		if g.Config.MinRating != nil {
+			return *g.Config.MinRating
+		}
+	}
+	return MinRating
+}
+
+type Image struct {
+	Name    string
+	Title   string
+	Caption string
+	Tags    []string
+	// Rating is the star rating from 0 to 5, -1 marks rejected photos.
+	Rating  int
+	Raw     string
+	Path    string
+	Thumb   string
+	Unbound string
+	Info    os.FileInfo
+	Kind    string
+
+	// Format is the output format of the large rendition.
+	Format string
+	// ThumbFormat is the output format of the thumbnail.
+	ThumbFormat string
+	// Renditions contains all downscaled versions of a photo,
+	// ordered from the thumbnail to the large image.
+	Renditions []*Rendition
+
+	// Poster is the still frame shown before a video is played.
+	Poster string
+	// Preview is a short animated summary of a video.
+	Preview string
+
+	// Metadata is the camera information, nil when not available.
+	Metadata *Metadata
+
+	// BlurHash is a compact representation of a placeholder for the image.
+	BlurHash string
+	// LQIP is a tiny inline preview of the image.
+	LQIP template.URL
+
+	// WebP and ThumbWebP are the WebP renditions of Path and Thumb,
+	// they are empty when WebP generation is disabled.
+	WebP      string
+	ThumbWebP string
+
+	// Original is the published copy of the source file,
+	// empty when originals are not published.
+	Original string
+	// Preset is the processing preset applied to the renditions.
+	Preset string
+
+	// Stack contains the similar images taken right after this one,
+	// StackTop is the image whose stack contains this image.
+	Stack    []*Image
+	StackTop *Image
+}
+
+func (image *Image) PageLink() string {
+	return path.Join("/", ReplaceExt(filepath.ToSlash(image.Unbound), ".html"))
+}
+
+func (image *Image) ImageLink() string {
+	return path.Join("/", filepath.ToSlash(image.Path))
+}
+
+func (image *Image) ThumbLink() string {
+	return path.Join("/", filepath.ToSlash(image.Thumb))
+}
+
+func (image *Image) PosterLink() string {
+	return path.Join("/", filepath.ToSlash(image.Poster))
+}
+
+func (image *Image) PreviewLink() string {
+	if image.Preview == "" {
+		return ""
+	}
+	return path.Join("/", filepath.ToSlash(image.Preview))
+}
+
+func (image *Image) WebPLink() string {
+	if image.WebP == "" {
+		return ""
+	}
+	return path.Join("/", filepath.ToSlash(image.WebP))
+}
+
+func (image *Image) ThumbWebPLink() string {
+	if image.ThumbWebP == "" {
+		return ""
+	}
+	return path.Join("/", filepath.ToSlash(image.ThumbWebP))
+}
+
+func (image *Image) OriginalLink() string {
+	if image.Original == "" {
+		return ""
+	}
+	return path.Join("/", filepath.ToSlash(image.Original))
+}
+
+// Added returns when the image was added, which is
+// the modification time of the source file.
+func (image *Image) Added() time.Time {
+	return image.Info.ModTime()
+}
+
+// Location returns where the image was taken, nil when unknown.
+func (image *Image) Location() *Location {
+	if image.Metadata == nil {
+		return nil
+	}
+	return image.Metadata.Location
+}
+
+// Large returns the largest rendition, nil when the image has none.
+func (image *Image) Large() *Rendition {
+	if len(image.Renditions) == 0 {
+		return nil
+	}
+	return image.Renditions[len(image.Renditions)-1]
+}
+
+// Srcset returns the renditions formatted for the srcset attribute.
+func (image *Image) Srcset() string {
+	return image.SrcsetUpTo(0)
+}
+
+// SrcsetUpTo formats the renditions for the srcset attribute,
+// skipping the ones wider than max when it's positive.
+func (image *Image) SrcsetUpTo(max int) string {
+	var entries []string
+	for _, rendition := range image.Renditions {
+		if rendition.Width <= 0 {
+			continue
+		}
+		if max > 0 && rendition.Width > max {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%s %dw", rendition.Link(), rendition.Width))
+	}
+	return strings.Join(entries, ", ")
+}
+
+// WebPSrcset returns the WebP renditions formatted for the srcset attribute.
+func (image *Image) WebPSrcset() string {
+	var entries []string
+	for _, rendition := range image.Renditions {
+		if rendition.Width > 0 && rendition.WebP != "" {
+			entries = append(entries, fmt.Sprintf("%s %dw", rendition.WebPLink(), rendition.Width))
+		}
+	}
+	return strings.Join(entries, ", ")
+}
+
+// Image kinds
+const (
+	// KindPhoto is a still image that is re-encoded for publishing.
+	KindPhoto = "photo"
+	// KindAnimation is an animated image that is published as is.
+	KindAnimation = "animation"
+	// KindVideo is a video with a poster frame.
+	KindVideo = "video"
+	// KindVector is a vector image that is published as is.
+	KindVector = "vector"
+)
+
+// IsSource returns whether a file with the extension can be used as an image source.
+func IsSource(path string) bool {
+	if imgproc.CanDecode(path) {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".mov":
+		return true
+	}
+	return false
+}
+
+// SourceKind returns the kind of image for a source file.
+func SourceKind(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gif":
+		return KindAnimation
+	case ".mp4", ".mov":
+		return KindVideo
+	case ".svg":
+		return KindVector
+	}
+	return KindPhoto
+}
+
+// Rendition is a downscaled version of an image.
+type Rendition struct {
+	Size    int
+	Format  string
+	Quality int
+	// Progressive is set when a JPEG rendition should be progressive.
+	Progressive bool
+	Path        string
+	// WebP is the WebP alternative of Path, empty when disabled.
+	WebP string
+
+	Width  int
+	Height int
+}
+
+func (rendition *Rendition) Link() string {
+	return path.Join("/", filepath.ToSlash(rendition.Path))
+}
+
+func (rendition *Rendition) WebPLink() string {
+	if rendition.WebP == "" {
+		return ""
+	}
+	return path.Join("/", filepath.ToSlash(rendition.WebP))
+}
+
+// UpdateSize reads the dimensions of the published file with size,
+// when they are not known from processing.
+func (rendition *Rendition) UpdateSize(size func(path string) (width, height int)) {
+	if rendition.Width > 0 && rendition.Height > 0 {
+		return
+	}
+	rendition.Width, rendition.Height = size(rendition.Path)
+}
+
+// SizeList is a sorted list of rendition sizes.
+type SizeList []int
+
+func (sizes *SizeList) String() string {
+	var xs []string
+	for _, size := range *sizes {
+		xs = append(xs, strconv.Itoa(size))
+	}
+	return strings.Join(xs, ",")
+}
+
+func (sizes *SizeList) Set(value string) error {
+	*sizes = nil
+	for _, x := range strings.Split(value, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(x))
+		if err != nil || size <= 0 {
+			return fmt.Errorf("invalid size %q", x)
+		}
+		*sizes = append(*sizes, size)
+	}
+	sort.Ints(*sizes)
+	unique := (*sizes)[:0]
+	for _, size := range *sizes {
+		if len(unique) == 0 || unique[len(unique)-1] != size {
+			unique = append(unique, size)
+		}
+	}
+	*sizes = unique
+	// the thumbnails and the large images are different renditions
+	if len(*sizes) < 2 {
+		return fmt.Errorf("at least two sizes are needed, for the thumbnails and the large images")
+	}
+	return nil
+}
+
+// Thumb returns the thumbnail size.
+func (sizes SizeList) Thumb() int { return sizes[0] }
+
+// Large returns the size used for image pages.
+func (sizes SizeList) Large() int { return sizes[len(sizes)-1] }
+
+// ReplaceExt replaces the extension of path with ext.
+func ReplaceExt(path, ext string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + ext
+}
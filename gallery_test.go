@@ -0,0 +1,33 @@
+package gallery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSizeList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  SizeList
+		err   bool
+	}{
+		{"256,1024", SizeList{256, 1024}, false},
+		{"2048, 256,1024", SizeList{256, 1024, 2048}, false},
+		{"256,1024,256", SizeList{256, 1024}, false},
+		{"1024", nil, true},
+		{"256,256", nil, true},
+		{"256,x", nil, true},
+		{"0,256", nil, true},
+	}
+	for _, test := range tests {
+		var sizes SizeList
+		err := sizes.Set(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("Set(%q) error %v", test.value, err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(sizes, test.want) {
+			t.Errorf("Set(%q) = %v, expected %v", test.value, sizes, test.want)
+		}
+	}
+}
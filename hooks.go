@@ -0,0 +1,69 @@
+package gallery
+
+// Hooks are called at fixed points of a build, they can modify the
+// galleries or write additional outputs. Plugins append to them in init.
+//
+// BeforeImage and AfterImage are called concurrently for different images.
+// An error from a Before hook skips the image or page.
+var (
+	// AfterScan is called once the galleries are loaded and sorted.
+	AfterScan []func(galleries map[string]*Gallery) error
+	// BeforeImage is called before the renditions of image are generated.
+	BeforeImage []func(gallery *Gallery, image *Image) error
+	// AfterImage is called after the renditions of image are generated.
+	AfterImage []func(gallery *Gallery, image *Image) error
+	// BeforePage is called with the data of a page before it's rendered.
+	BeforePage []func(name, template string, data map[string]interface{}) error
+	// AfterBuild is called after all the pages are written to output.
+	AfterBuild []func(galleries map[string]*Gallery, output string) error
+)
+
+// RunAfterScan calls the AfterScan hooks.
+func RunAfterScan(galleries map[string]*Gallery) error {
+	for _, hook := range AfterScan {
+		if err := hook(galleries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunBeforeImage calls the BeforeImage hooks.
+func RunBeforeImage(gallery *Gallery, image *Image) error {
+	for _, hook := range BeforeImage {
+		if err := hook(gallery, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAfterImage calls the AfterImage hooks.
+func RunAfterImage(gallery *Gallery, image *Image) error {
+	for _, hook := range AfterImage {
+		if err := hook(gallery, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunBeforePage calls the BeforePage hooks.
+func RunBeforePage(name, template string, data map[string]interface{}) error {
+	for _, hook := range BeforePage {
+		if err := hook(name, template, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAfterBuild calls the AfterBuild hooks.
+func RunAfterBuild(galleries map[string]*Gallery, output string) error {
+	for _, hook := range AfterBuild {
+		if err := hook(galleries, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
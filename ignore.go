@@ -0,0 +1,23 @@
+package gallery
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Ignore contains the name patterns of files and directories that are
+// skipped while scanning, e.g. hidden files and the thumbnails made by
+// the operating system or a NAS. Patterns use filepath.Match syntax
+// and are matched ignoring case.
+var Ignore = []string{".*", "Thumbs.db", "desktop.ini", "@eaDir", "#recycle", "#snapshot"}
+
+// IsIgnored returns whether the file or directory name matches one of the Ignore patterns.
+func IsIgnored(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range Ignore {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
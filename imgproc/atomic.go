@@ -0,0 +1,54 @@
+package imgproc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteAtomic calls write with a temporary path next to path and renames
+// the result into place once write succeeds, hence an interrupted build
+// never leaves a truncated file at path. The temporary path has the same
+// extension, since external tools pick the format from it.
+func WriteAtomic(path string, write func(tmp string) error) error {
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+
+	file, err := ioutil.TempFile(dir, ".tmp-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	file.Close()
+
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// temporary files are created private
+	os.Chmod(tmp, 0644)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// WriteFile writes data to path atomically.
+func WriteFile(path string, data []byte) error {
+	return WriteAtomic(path, func(tmp string) error {
+		return ioutil.WriteFile(tmp, data, 0644)
+	})
+}
+
+// LinkFile hard links src to dst, the file is copied when
+// linking isn't possible, e.g. across file systems.
+func LinkFile(src, dst string) error {
+	return WriteAtomic(dst, func(tmp string) error {
+		os.Remove(tmp)
+		if err := os.Link(src, tmp); err == nil {
+			return nil
+		}
+		return copyFile(src, tmp)
+	})
+}
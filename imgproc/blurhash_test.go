@@ -0,0 +1,48 @@
+package imgproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func TestBlurHash(t *testing.T) {
+	uniform := func(c color.Color) image.Image {
+		m := image.NewRGBA(image.Rect(0, 0, 8, 6))
+		draw.Draw(m, m.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		return m
+	}
+	black := uniform(color.Black)
+	white := uniform(color.White)
+	red := uniform(color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		name         string
+		m            image.Image
+		xcomp, ycomp int
+		want         string
+	}{
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), 4, 3, ""},
+		{"white dc", white, 1, 1, "00TSUA"},
+		{"red dc", red, 1, 1, "00TI:j"},
+		// black has no detail, each component is the middle value
+		{"black", black, 4, 3, "L00000" + strings.Repeat("fQ", 11)},
+	}
+	for _, test := range tests {
+		if got := BlurHash(test.m, test.xcomp, test.ycomp); got != test.want {
+			t.Errorf("%v: BlurHash = %q, expected %q", test.name, got, test.want)
+		}
+	}
+
+	// detail changes the components but not the length
+	gradient := image.NewGray(image.Rect(0, 0, 8, 6))
+	for i := range gradient.Pix {
+		gradient.Pix[i] = uint8(i % 8 * 32)
+	}
+	hash := BlurHash(gradient, 4, 3)
+	if len(hash) != 4+2*4*3 || strings.Count(hash, "fQ") == 11 {
+		t.Errorf("gradient BlurHash = %q", hash)
+	}
+}
@@ -0,0 +1,207 @@
+package imgproc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Aspect is a width to height ratio, zero means the original aspect.
+type Aspect float64
+
+func (aspect *Aspect) String() string {
+	if *aspect == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*aspect), 'g', -1, 64)
+}
+
+func (aspect *Aspect) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		*aspect = 0
+		return nil
+	}
+
+	var w, h float64
+	if _, err := fmt.Sscanf(value, "%g:%g", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return fmt.Errorf("invalid aspect %q, expected w:h", value)
+	}
+	*aspect = Aspect(w / h)
+	return nil
+}
+
+// Crop crops m to the aspect using the strategy to choose the region.
+func Crop(m image.Image, aspect float64, strategy string) image.Image {
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return m
+	}
+
+	cropw, croph := width, height
+	if float64(width)/float64(height) > aspect {
+		cropw = int(float64(height)*aspect + 0.5)
+	} else {
+		croph = int(float64(width)/aspect + 0.5)
+	}
+	if cropw == width && croph == height {
+		return m
+	}
+
+	var offset image.Point
+	switch strategy {
+	case "smart":
+		offset = smartOffset(m, cropw, croph)
+	case "face":
+		offset = faceOffset(m, cropw, croph)
+	default:
+		offset = image.Pt((width-cropw)/2, (height-croph)/2)
+	}
+
+	r := image.Rect(0, 0, cropw, croph).Add(bounds.Min).Add(offset)
+	dst := image.NewRGBA(image.Rect(0, 0, cropw, croph))
+	draw.Copy(dst, image.Point{}, m, r, draw.Src, nil)
+	return dst
+}
+
+// smartOffset finds the crop position that contains the most detail.
+//
+// The detail is estimated from a downsampled saliency map, which combines
+// edge strength and saturation, with a slight preference towards the center.
+func smartOffset(m image.Image, cropw, croph int) image.Point {
+	saliency, scale := saliencyMap(m, 128)
+	bounds := saliency.Bounds()
+
+	// project saliency onto the axis where the crop moves
+	horizontal := cropw < m.Bounds().Dx()
+	n := bounds.Dy()
+	window := int(float64(croph) * scale)
+	if horizontal {
+		n = bounds.Dx()
+		window = int(float64(cropw) * scale)
+	}
+	if window >= n || window <= 0 {
+		return image.Point{}
+	}
+
+	sums := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		total := 0.0
+		if horizontal {
+			for y := 0; y < bounds.Dy(); y++ {
+				total += float64(saliency.GrayAt(i, y).Y)
+			}
+		} else {
+			for x := 0; x < bounds.Dx(); x++ {
+				total += float64(saliency.GrayAt(x, i).Y)
+			}
+		}
+		sums[i+1] = sums[i] + total
+	}
+
+	best, bestScore := 0, math.Inf(-1)
+	center := float64(n-window) / 2
+	for start := 0; start+window <= n; start++ {
+		score := sums[start+window] - sums[start]
+		// penalize moving away from the center by up to 10%
+		if center > 0 {
+			score *= 1 - 0.1*math.Abs(float64(start)-center)/center
+		}
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+
+	offset := int(float64(best) / scale)
+	if horizontal {
+		return image.Pt(clamp(offset, 0, m.Bounds().Dx()-cropw), 0)
+	}
+	return image.Pt(0, clamp(offset, 0, m.Bounds().Dy()-croph))
+}
+
+// saliencyMap downsamples m to roughly size pixels on the longer side
+// and estimates how interesting each pixel is.
+func saliencyMap(m image.Image, size int) (*image.Gray, float64) {
+	bounds := m.Bounds()
+	scale := float64(size) / math.Max(float64(bounds.Dx()), float64(bounds.Dy()))
+	if scale > 1 {
+		scale = 1
+	}
+	w := int(float64(bounds.Dx())*scale + 0.5)
+	h := int(float64(bounds.Dy())*scale + 0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), m, bounds, draw.Src, nil)
+
+	luma := func(x, y int) float64 {
+		x, y = clamp(x, 0, w-1), clamp(y, 0, h-1)
+		c := small.RGBAAt(x, y)
+		return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+	}
+
+	saliency := image.NewGray(small.Bounds())
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			edge := math.Abs(4*luma(x, y) - luma(x-1, y) - luma(x+1, y) - luma(x, y-1) - luma(x, y+1))
+
+			c := small.RGBAAt(x, y)
+			max := math.Max(float64(c.R), math.Max(float64(c.G), float64(c.B)))
+			min := math.Min(float64(c.R), math.Min(float64(c.G), float64(c.B)))
+			saturation := 0.0
+			if max > 0 {
+				saturation = (max - min) / max
+			}
+
+			v := edge + saturation*64
+			saliency.SetGray(x, y, color.Gray{Y: uint8(math.Min(v, 255))})
+		}
+	}
+	return saliency, scale
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// Pad letterboxes m to the aspect by centering it on the background.
+func Pad(m image.Image, aspect float64, background color.Color) image.Image {
+	bounds := m.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return m
+	}
+
+	padw, padh := width, height
+	if float64(width)/float64(height) > aspect {
+		padh = int(float64(width)/aspect + 0.5)
+	} else {
+		padw = int(float64(height)*aspect + 0.5)
+	}
+	if padw == width && padh == height {
+		return m
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, padw, padh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	offset := image.Pt((padw-width)/2, (padh-height)/2)
+	draw.Draw(dst, image.Rect(0, 0, width, height).Add(offset), m, bounds.Min, draw.Over)
+	return dst
+}
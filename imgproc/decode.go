@@ -0,0 +1,100 @@
+// Package imgproc decodes, transforms and encodes images and videos.
+//
+// Formats without a Go implementation are handled by external tools,
+// their paths are configured with the package variables.
+package imgproc
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// HEIFConvert is the path to heif-convert for decoding HEIC/HEIF.
+var HEIFConvert = "heif-convert"
+
+// decoders contains decoders for source formats that are not handled by image.Decode,
+// oriented reports whether the decoded image has already been rotated upright.
+var decoders = map[string]func(path string) (m image.Image, oriented bool, err error){
+	".heic": DecodeHEIF,
+	".heif": DecodeHEIF,
+
+	".cr2": DecodeRAW,
+	".nef": DecodeRAW,
+	".arw": DecodeRAW,
+	".dng": DecodeRAW,
+
+	".svg": DecodeSVG,
+}
+
+// CanDecode returns whether a file with the extension can be decoded.
+func CanDecode(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := decoders[ext]; ok {
+		return true
+	}
+	switch ext {
+	case ".jpeg", ".jpg", ".png", ".tif", ".tiff", ".bmp", ".gif":
+		return true
+	}
+	return false
+}
+
+// IsExternal returns whether the file is decoded with an external tool.
+func IsExternal(path string) bool {
+	_, ok := decoders[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Decode decodes the image at path,
+// oriented reports whether the orientation has already been applied.
+func Decode(path string) (m image.Image, oriented bool, err error) {
+	if decode, ok := decoders[strings.ToLower(filepath.Ext(path))]; ok {
+		return decode(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	m, _, err = image.Decode(file)
+	return m, false, err
+}
+
+// DecodeHEIF decodes HEIC/HEIF images using the external heif-convert tool.
+//
+// heif-convert already applies the rotation and mirroring stored in
+// the container, hence the result must not be reoriented again.
+func DecodeHEIF(path string) (image.Image, bool, error) {
+	dir, err := ioutil.TempDir("", "gallery-heif")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "image.png")
+	cmd := exec.Command(HEIFConvert, path, out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, false, fmt.Errorf("heif-convert %v: %v: %s", path, err, output)
+	}
+
+	file, err := os.Open(out)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	m, _, err := image.Decode(file)
+	return m, true, err
+}
@@ -0,0 +1,27 @@
+package imgproc
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// DHash returns the difference hash of m, visually similar images have
+// hashes that differ in only a few bits.
+func DHash(m image.Image) uint64 {
+	small := imaging.Resize(imaging.Grayscale(m), 9, 8, imaging.Box)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(x+1, y)).(color.Gray).Y
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
@@ -0,0 +1,153 @@
+package imgproc
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// CWebP is the path to the cwebp encoder.
+	CWebP = "cwebp"
+	// GIF2WebP is the path to the gif2webp encoder.
+	GIF2WebP = "gif2webp"
+	// JPEGTran is the path to jpegtran for progressive JPEG encoding.
+	JPEGTran = "jpegtran"
+	// AVIFEnc is the path to the avifenc encoder.
+	AVIFEnc = "avifenc"
+)
+
+// Encoding settings used by Save.
+var (
+	// JPEGQuality is used when no quality is specified (1-100).
+	JPEGQuality = 93
+	// WebPQuality is the lossy WebP quality (0-100).
+	WebPQuality = 80
+	// AVIFQuality is the AVIF quality (0-100).
+	AVIFQuality = 60
+	// AVIFSpeed is the AVIF encoder speed (0 slowest - 10 fastest).
+	AVIFSpeed = 6
+)
+
+// FormatExt returns the file extension for an output format.
+func FormatExt(format string) string {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		return ".jpg"
+	case "png":
+		return ".png"
+	case "webp", "webp-lossless":
+		return ".webp"
+	case "avif":
+		return ".avif"
+	}
+	return ""
+}
+
+// IsLossless returns whether the output format preserves pixels exactly.
+func IsLossless(format string) bool {
+	switch strings.ToLower(format) {
+	case "png", "webp-lossless":
+		return true
+	}
+	return false
+}
+
+// Save encodes m to path using the specified output format,
+// quality is used for JPEG encoding and zero means JPEGQuality.
+func Save(m image.Image, path string, format string, quality int) error {
+	if strings.EqualFold(format, "webp-lossless") {
+		return SaveWebPLossless(m, path)
+	}
+
+	switch FormatExt(format) {
+	case ".jpg":
+		if quality <= 0 {
+			quality = JPEGQuality
+		}
+		return SaveJPG(m, path, quality)
+	case ".png":
+		return SavePNG(m, path)
+	case ".webp":
+		return SaveWebP(m, path, WebPQuality)
+	case ".avif":
+		return SaveAVIF(m, path, AVIFQuality, AVIFSpeed)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// SaveWebP encodes m as WebP using the external cwebp encoder.
+func SaveWebP(m image.Image, path string, quality int) error {
+	path = replaceExt(path, ".webp")
+	return encodeExternal(m, path, CWebP, "-quiet", "-q", fmt.Sprint(quality), "{in}", "-o", "{out}")
+}
+
+// SaveWebPLossless encodes m as lossless WebP using the external cwebp encoder.
+func SaveWebPLossless(m image.Image, path string) error {
+	path = replaceExt(path, ".webp")
+	return encodeExternal(m, path, CWebP, "-quiet", "-lossless", "-exact", "{in}", "-o", "{out}")
+}
+
+// MakeProgressive losslessly converts a baseline JPEG into a progressive JPEG using jpegtran.
+func MakeProgressive(path string) error {
+	return WriteAtomic(path, func(tmp string) error {
+		cmd := exec.Command(JPEGTran, "-progressive", "-optimize", "-copy", "all", "-outfile", tmp, path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("jpegtran %v: %v: %s", path, err, out)
+		}
+		return nil
+	})
+}
+
+// ConvertGIFToWebP converts an animated GIF to an animated WebP using gif2webp.
+func ConvertGIFToWebP(src, dst string, quality int) error {
+	return WriteAtomic(dst, func(tmp string) error {
+		cmd := exec.Command(GIF2WebP, "-quiet", "-mixed", "-q", fmt.Sprint(quality), src, "-o", tmp)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("gif2webp %v: %v: %s", dst, err, out)
+		}
+		return nil
+	})
+}
+
+// SaveAVIF encodes m as AVIF using the external avifenc encoder.
+func SaveAVIF(m image.Image, path string, quality, speed int) error {
+	path = replaceExt(path, ".avif")
+	return encodeExternal(m, path, AVIFEnc, "-q", fmt.Sprint(quality), "-s", fmt.Sprint(speed), "{in}", "{out}")
+}
+
+// encodeExternal writes m as a temporary PNG and runs an external encoder,
+// where "{in}" and "{out}" in args are replaced with the input and output paths.
+func encodeExternal(m image.Image, path string, encoder string, args ...string) error {
+	tmp, err := ioutil.TempFile("", "gallery-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = png.Encode(tmp, m)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return WriteAtomic(path, func(out string) error {
+		expanded := make([]string, len(args))
+		for i, arg := range args {
+			arg = strings.Replace(arg, "{in}", tmp.Name(), -1)
+			arg = strings.Replace(arg, "{out}", out, -1)
+			expanded[i] = arg
+		}
+
+		cmd := exec.Command(encoder, expanded...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s %v: %v: %s", filepath.Base(encoder), path, err, output)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,190 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// EmbedExif copies the EXIF fields from source into the JPEG at path.
+func EmbedExif(path, source string, fields []exif.FieldName) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	x, err := exif.Decode(f)
+	f.Close()
+	if err != nil || x == nil {
+		// nothing to keep
+		return nil
+	}
+
+	segment, err := exifSegment(x, fields)
+	if err != nil || segment == nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("%v: not a JPEG", path)
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write(segment)
+	out.Write(data[2:])
+	return WriteFile(path, out.Bytes())
+}
+
+// ifd0Fields are the fields stored in the primary IFD, other
+// non-GPS fields are stored in the Exif sub-IFD.
+var ifd0Fields = map[exif.FieldName]bool{
+	exif.ImageDescription: true,
+	exif.Make:             true,
+	exif.Model:            true,
+	exif.Software:         true,
+	exif.DateTime:         true,
+	exif.Artist:           true,
+	exif.Copyright:        true,
+	exif.XResolution:      true,
+	exif.YResolution:      true,
+	exif.ResolutionUnit:   true,
+}
+
+const (
+	tagExifPointer = 0x8769
+	tagGPSPointer  = 0x8825
+)
+
+// exifSegment creates a JPEG APP1 segment containing the fields.
+func exifSegment(x *exif.Exif, fields []exif.FieldName) ([]byte, error) {
+	var ifd0, exifIFD, gpsIFD []*tiff.Tag
+	for _, name := range fields {
+		tag, err := x.Get(name)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(string(name), "GPS"):
+			gpsIFD = append(gpsIFD, tag)
+		case ifd0Fields[name]:
+			ifd0 = append(ifd0, tag)
+		default:
+			exifIFD = append(exifIFD, tag)
+		}
+	}
+	if len(ifd0)+len(exifIFD)+len(gpsIFD) == 0 {
+		return nil, nil
+	}
+
+	order := x.Tiff.Order
+	// pointer values are filled in once the layout is known
+	var exifPointer, gpsPointer *tiff.Tag
+	if len(exifIFD) > 0 {
+		exifPointer = &tiff.Tag{Id: tagExifPointer, Type: tiff.DTLong, Count: 1, Val: make([]byte, 4)}
+		ifd0 = append(ifd0, exifPointer)
+	}
+	if len(gpsIFD) > 0 {
+		gpsPointer = &tiff.Tag{Id: tagGPSPointer, Type: tiff.DTLong, Count: 1, Val: make([]byte, 4)}
+		ifd0 = append(ifd0, gpsPointer)
+	}
+
+	// layout: header, IFD0 + data, Exif IFD + data, GPS IFD + data
+	offset := uint32(8)
+	ifd0Offset := offset
+	offset += ifdSize(ifd0)
+	exifOffset := offset
+	offset += ifdSize(exifIFD)
+	gpsOffset := offset
+
+	if exifPointer != nil {
+		order.PutUint32(exifPointer.Val, exifOffset)
+	}
+	if gpsPointer != nil {
+		order.PutUint32(gpsPointer.Val, gpsOffset)
+	}
+
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, ifd0Offset)
+
+	writeIFD(&buf, order, ifd0, ifd0Offset)
+	if len(exifIFD) > 0 {
+		writeIFD(&buf, order, exifIFD, exifOffset)
+	}
+	if len(gpsIFD) > 0 {
+		writeIFD(&buf, order, gpsIFD, gpsOffset)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), buf.Bytes()...)
+	if len(payload)+2 > 0xFFFF {
+		return nil, errors.New("kept EXIF metadata does not fit into a JPEG segment")
+	}
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...), nil
+}
+
+// ifdSize returns the size of the IFD including the values stored outside of it.
+func ifdSize(tags []*tiff.Tag) uint32 {
+	if len(tags) == 0 {
+		return 0
+	}
+	size := uint32(2 + 12*len(tags) + 4)
+	for _, tag := range tags {
+		if len(tag.Val) > 4 {
+			size += uint32(len(tag.Val)+1) &^ 1
+		}
+	}
+	return size
+}
+
+// writeIFD writes the IFD, which starts at offset, followed by its values.
+func writeIFD(buf *bytes.Buffer, order binary.ByteOrder, tags []*tiff.Tag, offset uint32) {
+	sort.Slice(tags, func(i, k int) bool { return tags[i].Id < tags[k].Id })
+
+	var data bytes.Buffer
+	dataOffset := offset + uint32(2+12*len(tags)+4)
+
+	binary.Write(buf, order, uint16(len(tags)))
+	for _, tag := range tags {
+		binary.Write(buf, order, tag.Id)
+		binary.Write(buf, order, uint16(tag.Type))
+		binary.Write(buf, order, tag.Count)
+		if len(tag.Val) <= 4 {
+			var value [4]byte
+			copy(value[:], tag.Val)
+			buf.Write(value[:])
+			continue
+		}
+		binary.Write(buf, order, dataOffset+uint32(data.Len()))
+		data.Write(tag.Val)
+		if data.Len()%2 == 1 {
+			data.WriteByte(0)
+		}
+	}
+	// no next IFD
+	binary.Write(buf, order, uint32(0))
+	buf.Write(data.Bytes())
+}
@@ -0,0 +1,119 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// testExif returns EXIF metadata with fields in IFD0, the Exif IFD and the GPS IFD.
+func testExif(t *testing.T, order binary.ByteOrder) *exif.Exif {
+	t.Helper()
+	ascii := func(id uint16, s string) *tiff.Tag {
+		return &tiff.Tag{Id: id, Type: tiff.DTAscii, Count: uint32(len(s) + 1), Val: append([]byte(s), 0)}
+	}
+	long := func(id uint16, v uint32) *tiff.Tag {
+		val := make([]byte, 4)
+		order.PutUint32(val, v)
+		return &tiff.Tag{Id: id, Type: tiff.DTLong, Count: 1, Val: val}
+	}
+	short := func(id uint16, v uint16) *tiff.Tag {
+		val := make([]byte, 2)
+		order.PutUint16(val, v)
+		return &tiff.Tag{Id: id, Type: tiff.DTShort, Count: 1, Val: val}
+	}
+
+	exifIFD := []*tiff.Tag{short(0x8827, 400), ascii(0x9003, "2020:01:02 03:04:05")}
+	gpsIFD := []*tiff.Tag{ascii(0x1, "N")}
+	exifPointer, gpsPointer := long(tagExifPointer, 0), long(tagGPSPointer, 0)
+	ifd0 := []*tiff.Tag{ascii(0x10F, "Canon"), ascii(0x110, "Canon EOS 5D Mark IV"), exifPointer, gpsPointer}
+	exifOffset := 8 + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exifIFD)
+	order.PutUint32(exifPointer.Val, exifOffset)
+	order.PutUint32(gpsPointer.Val, gpsOffset)
+
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, uint32(8))
+	writeIFD(&buf, order, ifd0, 8)
+	writeIFD(&buf, order, exifIFD, exifOffset)
+	writeIFD(&buf, order, gpsIFD, gpsOffset)
+
+	x, err := exif.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestExifSegment(t *testing.T) {
+	all := []exif.FieldName{exif.Make, exif.Model, exif.ISOSpeedRatings, exif.DateTimeOriginal, exif.GPSLatitudeRef}
+	tests := []struct {
+		fields []exif.FieldName
+		want   []exif.FieldName
+	}{
+		{[]exif.FieldName{exif.Make}, []exif.FieldName{exif.Make}},
+		{[]exif.FieldName{exif.ISOSpeedRatings, exif.DateTimeOriginal}, []exif.FieldName{exif.ISOSpeedRatings, exif.DateTimeOriginal}},
+		{[]exif.FieldName{exif.GPSLatitudeRef, exif.Model}, []exif.FieldName{exif.GPSLatitudeRef, exif.Model}},
+		{all, all},
+		// fields missing from the source are skipped
+		{[]exif.FieldName{exif.Software, exif.Model}, []exif.FieldName{exif.Model}},
+		{[]exif.FieldName{exif.Software}, nil},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		source := testExif(t, order)
+		for _, test := range tests {
+			segment, err := exifSegment(source, test.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want == nil {
+				if segment != nil {
+					t.Errorf("%v %v: segment created without fields", order, test.fields)
+				}
+				continue
+			}
+
+			if len(segment) < 4 || segment[0] != 0xFF || segment[1] != 0xE1 ||
+				int(binary.BigEndian.Uint16(segment[2:])) != len(segment)-2 {
+				t.Errorf("%v %v: invalid APP1 segment % x", order, test.fields, segment[:4])
+				continue
+			}
+			x, err := exif.Decode(bytes.NewReader(segment[4:]))
+			if err != nil {
+				t.Errorf("%v %v: %v", order, test.fields, err)
+				continue
+			}
+
+			kept := map[exif.FieldName]bool{}
+			for _, name := range test.want {
+				kept[name] = true
+			}
+			for _, name := range all {
+				tag, err := x.Get(name)
+				if !kept[name] {
+					if err == nil {
+						t.Errorf("%v %v: %v kept", order, test.fields, name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%v %v: %v missing: %v", order, test.fields, name, err)
+					continue
+				}
+				original, _ := source.Get(name)
+				if tag.Type != original.Type || tag.Count != original.Count || !bytes.Equal(tag.Val, original.Val) {
+					t.Errorf("%v %v: %v is %v, expected %v", order, test.fields, name, tag, original)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package imgproc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"golang.org/x/image/draw"
+)
+
+// FaceDetector is an external face detector command, invoked with
+// an image path and printing "x y w h" per face. When it's empty
+// faces are located by skin tone.
+var FaceDetector = ""
+
+// faceOffset finds the crop position that keeps the detected faces in frame,
+// when no faces are found it falls back to smartOffset.
+func faceOffset(m image.Image, cropw, croph int) image.Point {
+	bounds := m.Bounds()
+
+	var center image.Point
+	var found bool
+	if FaceDetector != "" {
+		faces, err := detectFaces(m, FaceDetector)
+		if err != nil {
+			slog.Warn("face detection failed, cropping by content", "err", err)
+		}
+		if len(faces) > 0 {
+			union := faces[0]
+			for _, face := range faces[1:] {
+				union = union.Union(face)
+			}
+			// when all faces don't fit, prefer the largest one
+			if union.Dx() > cropw || union.Dy() > croph {
+				union = largestRect(faces)
+			}
+			center, found = image.Pt((union.Min.X+union.Max.X)/2, (union.Min.Y+union.Max.Y)/2), true
+		}
+	} else {
+		center, found = skinCenter(m)
+	}
+
+	if !found {
+		return smartOffset(m, cropw, croph)
+	}
+
+	return image.Pt(
+		clamp(center.X-bounds.Min.X-cropw/2, 0, bounds.Dx()-cropw),
+		clamp(center.Y-bounds.Min.Y-croph/2, 0, bounds.Dy()-croph),
+	)
+}
+
+// detectFaces runs an external face detector on a downsampled copy of m.
+func detectFaces(m image.Image, detector string) ([]image.Rectangle, error) {
+	const size = 512
+
+	bounds := m.Bounds()
+	scale := 1.0
+	if bounds.Dx() > size || bounds.Dy() > size {
+		scale = float64(size) / float64(max(bounds.Dx(), bounds.Dy()))
+	}
+	small := image.NewRGBA(image.Rect(0, 0, int(float64(bounds.Dx())*scale), int(float64(bounds.Dy())*scale)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), m, bounds, draw.Src, nil)
+
+	tmp, err := ioutil.TempFile("", "gallery-face-*.png")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = png.Encode(tmp, small)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(detector, tmp.Name()).Output()
+	if err != nil {
+		return nil, fmt.Errorf("face detector: %v", err)
+	}
+
+	var faces []image.Rectangle
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var x, y, w, h float64
+		if _, err := fmt.Sscan(scanner.Text(), &x, &y, &w, &h); err != nil {
+			continue
+		}
+		faces = append(faces, image.Rect(
+			int(x/scale), int(y/scale), int((x+w)/scale), int((y+h)/scale),
+		).Add(bounds.Min))
+	}
+	return faces, scanner.Err()
+}
+
+// skinCenter finds the center of skin toned pixels in m,
+// which is a cheap approximation for where people are.
+func skinCenter(m image.Image) (image.Point, bool) {
+	const size = 128
+
+	bounds := m.Bounds()
+	scale := 1.0
+	if bounds.Dx() > size || bounds.Dy() > size {
+		scale = float64(size) / float64(max(bounds.Dx(), bounds.Dy()))
+	}
+	small := image.NewRGBA(image.Rect(0, 0, max(int(float64(bounds.Dx())*scale), 1), max(int(float64(bounds.Dy())*scale), 1)))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), m, bounds, draw.Src, nil)
+
+	var sumx, sumy, count float64
+	for y := 0; y < small.Bounds().Dy(); y++ {
+		for x := 0; x < small.Bounds().Dx(); x++ {
+			c := small.RGBAAt(x, y)
+			_, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			if 77 <= cb && cb <= 127 && 133 <= cr && cr <= 173 {
+				sumx += float64(x)
+				sumy += float64(y)
+				count++
+			}
+		}
+	}
+
+	// ignore images where skin tones are negligible
+	total := float64(small.Bounds().Dx() * small.Bounds().Dy())
+	if count < total*0.01 {
+		return image.Point{}, false
+	}
+
+	return image.Pt(
+		bounds.Min.X+int(sumx/count/scale),
+		bounds.Min.Y+int(sumy/count/scale),
+	), true
+}
+
+func largestRect(rects []image.Rectangle) image.Rectangle {
+	largest := rects[0]
+	for _, r := range rects[1:] {
+		if r.Dx()*r.Dy() > largest.Dx()*largest.Dy() {
+			largest = r
+		}
+	}
+	return largest
+}
@@ -0,0 +1,300 @@
+package imgproc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"io/ioutil"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// ReadICC returns the ICC profile embedded in JPEG or PNG data,
+// it returns nil when there isn't one.
+func ReadICC(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return readJPEGICC(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGICC(data)
+	}
+	return nil
+}
+
+// readJPEGICC joins the profile chunks from the APP2 segments.
+func readJPEGICC(data []byte) []byte {
+	const marker = "ICC_PROFILE\x00"
+
+	chunks := map[byte][]byte{}
+	count := byte(0)
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		kind := data[i+1]
+		if kind == 0xD8 || kind == 0x01 || (kind >= 0xD0 && kind <= 0xD7) {
+			i += 2
+			continue
+		}
+		if kind == 0xDA || kind == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		payload := data[i+4 : i+2+length]
+		if kind == 0xE2 && len(payload) > len(marker)+2 && string(payload[:len(marker)]) == marker {
+			count = payload[len(marker)+1]
+			chunks[payload[len(marker)]] = payload[len(marker)+2:]
+		}
+		i += 2 + length
+	}
+
+	var profile []byte
+	for seq := byte(1); seq <= count && count > 0; seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile
+}
+
+// readPNGICC decompresses the profile from the iCCP chunk.
+func readPNGICC(data []byte) []byte {
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		if length < 0 || i+12+length > len(data) || kind == "IDAT" {
+			return nil
+		}
+		if kind == "iCCP" {
+			chunk := data[i+8 : i+8+length]
+			// profile name, null separator and compression method
+			name := bytes.IndexByte(chunk, 0)
+			if name < 0 || name+2 > len(chunk) {
+				return nil
+			}
+			r, err := zlib.NewReader(bytes.NewReader(chunk[name+2:]))
+			if err != nil {
+				return nil
+			}
+			profile, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil
+			}
+			return profile
+		}
+		i += 12 + length
+	}
+	return nil
+}
+
+// xyzToSRGB converts D50 adapted XYZ into linear sRGB.
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// srgbEncode converts linear values, scaled to 0..4095, into sRGB.
+var srgbEncode = func() (table [4096]uint8) {
+	for i := range table {
+		v := float64(i) / 4095
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		table[i] = uint8(math.Round(v * 255))
+	}
+	return table
+}()
+
+// srgbDecode converts a sRGB value into linear.
+func srgbDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// colorTransform converts the values of a matrix/TRC RGB profile into sRGB.
+type colorTransform struct {
+	// linear contains the tone curves of the channels.
+	linear [3][256]float64
+	matrix [3][3]float64
+}
+
+// parseProfile reads the tone curves and primaries of a RGB display profile,
+// other kinds of profiles aren't supported.
+func parseProfile(profile []byte) (*colorTransform, bool) {
+	if len(profile) < 132 || string(profile[16:20]) != "RGB " || string(profile[20:24]) != "XYZ " {
+		return nil, false
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(profile) {
+			return nil, false
+		}
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset < 0 || size < 8 || offset+size > len(profile) {
+			continue
+		}
+		tags[string(profile[entry:entry+4])] = profile[offset : offset+size]
+	}
+
+	t := &colorTransform{}
+	var primaries [3][3]float64
+	for c, name := range []string{"r", "g", "b"} {
+		xyz, ok := tags[name+"XYZ"]
+		if !ok || len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+			return nil, false
+		}
+		for k := 0; k < 3; k++ {
+			primaries[k][c] = s15Fixed16(xyz[8+4*k:])
+		}
+
+		curve, ok := parseCurve(tags[name+"TRC"])
+		if !ok {
+			return nil, false
+		}
+		for i := range t.linear[c] {
+			t.linear[c][i] = curve(float64(i) / 255)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 3; k++ {
+			for j := 0; j < 3; j++ {
+				t.matrix[i][k] += xyzToSRGB[i][j] * primaries[j][k]
+			}
+		}
+	}
+	return t, true
+}
+
+// parseCurve reads a curv or para tone curve.
+func parseCurve(tag []byte) (func(float64) float64, bool) {
+	if len(tag) < 12 {
+		return nil, false
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+2*n {
+			return nil, false
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, true
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, true
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return func(x float64) float64 {
+			p := x * float64(n-1)
+			i := int(p)
+			if i >= n-1 {
+				return table[n-1]
+			}
+			return table[i] + (table[i+1]-table[i])*(p-float64(i))
+		}, true
+	case "para":
+		kind := binary.BigEndian.Uint16(tag[8:])
+		counts := map[uint16]int{0: 1, 1: 3, 2: 4, 3: 5, 4: 7}
+		n, ok := counts[kind]
+		if !ok || len(tag) < 12+4*n {
+			return nil, false
+		}
+		var p [7]float64
+		for i := 0; i < n; i++ {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		// Y = (aX+b)^g + e for X >= d, otherwise Y = cX + f
+		g, a, b := p[0], p[1], p[2]
+		if n == 1 {
+			a = 1
+		} else if a == 0 {
+			return nil, false
+		}
+		var c, d, e, f float64
+		switch kind {
+		case 1:
+			d = -b / a
+		case 2:
+			d, e, f = -b/a, p[3], p[3]
+		case 3:
+			c, d = p[3], p[4]
+		case 4:
+			c, d, e, f = p[3], p[4], p[5], p[6]
+		}
+		return func(x float64) float64 {
+			if x >= d {
+				return math.Pow(math.Max(a*x+b, 0), g) + e
+			}
+			return c*x + f
+		}, true
+	}
+	return nil, false
+}
+
+// s15Fixed16 decodes a signed fixed point number.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// isSRGB returns whether the transform doesn't noticeably change the colors.
+func (t *colorTransform) isSRGB() bool {
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 3; k++ {
+			identity := 0.0
+			if i == k {
+				identity = 1
+			}
+			if math.Abs(t.matrix[i][k]-identity) > 0.01 {
+				return false
+			}
+		}
+		for v := range t.linear[i] {
+			if math.Abs(t.linear[i][v]-srgbDecode(float64(v)/255)) > 0.002 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ToSRGB converts m from the colors of the ICC profile into sRGB, images with
+// a sRGB or an unsupported profile, e.g. CMYK or LUT based, are returned as is.
+func ToSRGB(m image.Image, profile []byte) image.Image {
+	t, ok := parseProfile(profile)
+	if !ok || t.isSRGB() {
+		return m
+	}
+
+	dst := imaging.Clone(m)
+	for i := 0; i+3 < len(dst.Pix); i += 4 {
+		r := t.linear[0][dst.Pix[i+0]]
+		g := t.linear[1][dst.Pix[i+1]]
+		b := t.linear[2][dst.Pix[i+2]]
+		for c := 0; c < 3; c++ {
+			v := t.matrix[c][0]*r + t.matrix[c][1]*g + t.matrix[c][2]*b
+			v = math.Max(0, math.Min(1, v))
+			dst.Pix[i+c] = srgbEncode[int(v*4095+0.5)]
+		}
+	}
+	return dst
+}
@@ -0,0 +1,234 @@
+package imgproc
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+)
+
+// Load decodes the image at path, rotates it upright and
+// converts the colors of an embedded ICC profile into sRGB.
+//
+// The file is read once for both the pixels and the orientation,
+// which avoids a second read from slow network storage.
+func Load(path string) (image.Image, error) {
+	if _, ok := decoders[strings.ToLower(filepath.Ext(path))]; ok {
+		m, oriented, err := Decode(path)
+		if err != nil {
+			return nil, err
+		}
+		if oriented {
+			return m, nil
+		}
+		return Reorient(m, ExifOrientation(path)), nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if profile := ReadICC(data); profile != nil {
+		m = ToSRGB(m, profile)
+	}
+	return Reorient(m, readOrientation(bytes.NewReader(data))), nil
+}
+
+// Size returns the dimensions of an image file by reading its header.
+func Size(path string) (width, height int) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+	return SizeOf(file)
+}
+
+// SizeOf returns the dimensions of the image read from r by decoding its header.
+func SizeOf(r io.Reader) (width, height int) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+func Downscale(m image.Image, maxwidth int) image.Image {
+	return DownscaleFilter(m, maxwidth, "catmullrom")
+}
+
+// DownscaleFilter is like Downscale, but resamples using the named filter.
+func DownscaleFilter(m image.Image, maxwidth int, filter string) image.Image {
+	if m.Bounds().Dx() <= maxwidth {
+		return m
+	}
+
+	interpolator, ok := Filters[filter]
+	if !ok {
+		interpolator = draw.CatmullRom
+	}
+
+	targetSize := image.Point{0, maxwidth}
+	targetSize.X = m.Bounds().Dx() * maxwidth / m.Bounds().Dy()
+	inner := image.Rectangle{image.ZP, targetSize}
+	rgba := image.NewRGBA(inner)
+	interpolator.Scale(rgba, rgba.Bounds(), m, m.Bounds(), draw.Over, nil)
+	return rgba
+}
+
+// Filters contains the resampling filters by name,
+// from the fastest to the sharpest.
+var Filters = map[string]draw.Interpolator{
+	"nearest":    draw.NearestNeighbor,
+	"bilinear":   draw.ApproxBiLinear,
+	"catmullrom": draw.CatmullRom,
+	"lanczos":    Lanczos,
+}
+
+// Lanczos is the Lanczos3 kernel, it's slightly sharper than CatmullRom.
+var Lanczos = &draw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}}
+
+func SaveJPG(m image.Image, path string, quality int) error {
+	path = replaceExt(path, ".jpg")
+	return WriteAtomic(path, func(tmp string) error {
+		file, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if err := jpeg.Encode(file, m, &jpeg.Options{Quality: quality}); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+func SavePNG(m image.Image, path string) error {
+	path = replaceExt(path, ".png")
+	return WriteAtomic(path, func(tmp string) error {
+		file, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(file, m); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+func replaceExt(path, ext string) string {
+	return path[:len(path)-len(filepath.Ext(path))] + ext
+}
+
+func ExifOrientation(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return topLeftSide
+	}
+	defer f.Close()
+	return readOrientation(f)
+}
+
+// readOrientation reads the EXIF orientation from r.
+func readOrientation(r io.Reader) int {
+	x, err := exif.Decode(r)
+	if err != nil || x == nil {
+		return topLeftSide
+	}
+
+	orient, err := x.Get(exif.Orientation)
+	if err != nil || orient == nil {
+		return topLeftSide
+	}
+
+	v, err := orient.Int(0)
+	if err != nil {
+		return topLeftSide
+	}
+
+	return v
+}
+
+// Exif Orientation Tag values
+// http://sylvana.net/jpegcrop/exif_orientation.html
+const (
+	topLeftSide     = 1
+	topRightSide    = 2
+	bottomRightSide = 3
+	bottomLeftSide  = 4
+	leftSideTop     = 5
+	rightSideTop    = 6
+	rightSideBottom = 7
+	leftSideBottom  = 8
+)
+
+// Reorient rotates and flips img upright according to the EXIF orientation.
+func Reorient(img image.Image, orient int) *image.NRGBA {
+	switch orient {
+	case topLeftSide:
+		return imaging.Clone(img)
+	case topRightSide:
+		return imaging.FlipV(img)
+	case bottomRightSide:
+		return imaging.Rotate180(img)
+	case bottomLeftSide:
+		return imaging.Rotate180(imaging.FlipV(img))
+	case leftSideTop:
+		return imaging.Rotate270(imaging.FlipV(img))
+	case rightSideTop:
+		return imaging.Rotate270(img)
+	case rightSideBottom:
+		return imaging.Rotate90(imaging.FlipV(img))
+	case leftSideBottom:
+		return imaging.Rotate90(img)
+	}
+	return imaging.Clone(img)
+}
+
+// CopyFile copies the file at src to dst.
+func CopyFile(src, dst string) (err error) {
+	return WriteAtomic(dst, func(tmp string) error {
+		return copyFile(src, tmp)
+	})
+}
+
+// copyFile copies the file at src to dst in place.
+func copyFile(src, dst string) error {
+	srcf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcf.Close()
+
+	dstf, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstf, srcf); err != nil {
+		dstf.Close()
+		return err
+	}
+	return dstf.Close()
+}
@@ -0,0 +1,34 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// LQIPWidth is the width of the inline preview.
+const LQIPWidth = 20
+
+// LQIP creates a tiny JPEG preview of m as a data URI.
+func LQIP(m image.Image) string {
+	bounds := m.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return ""
+	}
+
+	height := bounds.Dy() * LQIPWidth / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	small := image.NewRGBA(image.Rect(0, 0, LQIPWidth, height))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), m, bounds, draw.Src, nil)
+
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, small, &jpeg.Options{Quality: 50}); err != nil {
+		return ""
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
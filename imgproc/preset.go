@@ -0,0 +1,46 @@
+package imgproc
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Presets contains the processing presets that can be applied to photos.
+var Presets = map[string]func(m image.Image) image.Image{
+	// bw converts to grayscale with a slight contrast boost.
+	"bw": func(m image.Image) image.Image {
+		return imaging.AdjustSigmoid(imaging.Grayscale(m), 0.5, 3)
+	},
+	// sepia tones the photo brown like an old print.
+	"sepia": func(m image.Image) image.Image {
+		return imaging.AdjustFunc(m, func(c color.NRGBA) color.NRGBA {
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+			return color.NRGBA{
+				R: clampUint8(0.393*r + 0.769*g + 0.189*b),
+				G: clampUint8(0.349*r + 0.686*g + 0.168*b),
+				B: clampUint8(0.272*r + 0.534*g + 0.131*b),
+				A: c.A,
+			}
+		})
+	},
+	// contrast applies a gentle S-curve.
+	"contrast": func(m image.Image) image.Image {
+		return imaging.AdjustSigmoid(m, 0.5, 4)
+	},
+}
+
+// ApplyPreset applies the named preset to m, an empty name returns m as is.
+func ApplyPreset(m image.Image, name string) image.Image {
+	preset, ok := Presets[name]
+	if !ok {
+		return m
+	}
+	return preset(m)
+}
+
+func clampUint8(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
@@ -0,0 +1,66 @@
+package imgproc
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// Processor decodes, orients, resizes and encodes photos.
+type Processor interface {
+	// Load decodes the image at path and rotates it upright,
+	// the result may be downscaled to size, but not below it.
+	Load(path string, size int) (image.Image, error)
+	// Downscale scales m down to size resampling with the filter,
+	// see Filters for the names.
+	Downscale(m image.Image, size int, filter string) image.Image
+	// Save encodes m to path in the format.
+	Save(m image.Image, path, format string, quality int) error
+}
+
+// Renderer is a processor that creates renditions straight from the source,
+// without decoding it in Go.
+type Renderer interface {
+	Processor
+	// Renders returns whether the source at path can be rendered into the format.
+	Renders(path, format string) bool
+	// Render scales the source at path down to size and encodes it to out
+	// in the format, the result is upright.
+	Render(path, out string, size int, format string, quality int) error
+}
+
+// Processors contains the available processors by name.
+var Processors = map[string]Processor{
+	"go":   Go{},
+	"vips": VIPS{},
+}
+
+// FindProcessor returns the processor with the name.
+func FindProcessor(name string) (Processor, error) {
+	processor, ok := Processors[name]
+	if !ok {
+		var names []string
+		for name := range Processors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown processor %q, expected one of %v", name, names)
+	}
+	return processor, nil
+}
+
+// Go is the pure Go processor.
+type Go struct{}
+
+// Load decodes the image at full size.
+func (Go) Load(path string, size int) (image.Image, error) { return Load(path) }
+
+// Downscale scales m down to size resampling with the filter.
+func (Go) Downscale(m image.Image, size int, filter string) image.Image {
+	return DownscaleFilter(m, size, filter)
+}
+
+// Save encodes m to path in the format.
+func (Go) Save(m image.Image, path, format string, quality int) error {
+	return Save(m, path, format, quality)
+}
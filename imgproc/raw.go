@@ -0,0 +1,192 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+
+	"golang.org/x/image/tiff"
+)
+
+// DCRaw is the path to dcraw for developing camera RAW files,
+// when it's empty the embedded preview is used.
+var DCRaw = ""
+
+// DecodeRAW decodes a camera RAW file.
+//
+// When dcraw is configured the RAW data is developed, otherwise the
+// largest embedded JPEG preview is used.
+func DecodeRAW(path string) (image.Image, bool, error) {
+	if DCRaw != "" {
+		// dcraw rotates the output according to the camera orientation
+		m, err := DevelopRAW(path)
+		return m, true, err
+	}
+	m, err := RAWPreview(path)
+	return m, false, err
+}
+
+// DevelopRAW develops the RAW file with dcraw using camera white balance.
+func DevelopRAW(path string) (image.Image, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(DCRaw, "-c", "-w", "-T", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("dcraw %v: %v: %s", path, err, stderr.Bytes())
+	}
+	return tiff.Decode(bytes.NewReader(out))
+}
+
+// RAWPreview extracts the largest decodable JPEG preview
+// embedded in a TIFF based RAW file (CR2, NEF, ARW, DNG).
+func RAWPreview(path string) (image.Image, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	previews, err := rawPreviews(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	sort.Slice(previews, func(i, k int) bool {
+		return len(previews[i]) > len(previews[k])
+	})
+
+	for _, preview := range previews {
+		// lossless JPEG raw data also starts with SOI, but fails to decode
+		m, err := jpeg.Decode(bytes.NewReader(preview))
+		if err == nil {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%v: no embedded preview found", path)
+}
+
+// TIFF tags used for locating previews
+const (
+	tagCompression     = 0x103
+	tagStripOffsets    = 0x111
+	tagStripByteCounts = 0x117
+	tagSubIFDs         = 0x14A
+	tagJPEGOffset      = 0x201
+	tagJPEGLength      = 0x202
+	tagExifIFD         = 0x8769
+)
+
+// rawPreviews returns all JPEG streams referenced from the TIFF structure.
+func rawPreviews(data []byte) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, errors.New("file too short")
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("not a TIFF based RAW file")
+	}
+
+	var previews [][]byte
+	addPreview := func(offset, length uint32) {
+		end := uint64(offset) + uint64(length)
+		if length < 2 || end > uint64(len(data)) {
+			return
+		}
+		preview := data[offset:end]
+		if preview[0] == 0xFF && preview[1] == 0xD8 {
+			previews = append(previews, preview)
+		}
+	}
+
+	visited := map[uint32]bool{}
+	var walk func(offset uint32)
+	walk = func(offset uint32) {
+		for offset != 0 && !visited[offset] && uint64(offset)+2 <= uint64(len(data)) {
+			visited[offset] = true
+
+			count := uint32(order.Uint16(data[offset:]))
+			if uint64(offset)+2+uint64(count)*12+4 > uint64(len(data)) {
+				return
+			}
+
+			tags := map[uint16][]uint32{}
+			for i := uint32(0); i < count; i++ {
+				entry := data[offset+2+i*12:]
+				tags[order.Uint16(entry[0:])] = tiffValues(data, order, entry)
+			}
+
+			if offsets, lengths := tags[tagJPEGOffset], tags[tagJPEGLength]; len(offsets) == 1 && len(lengths) == 1 {
+				addPreview(offsets[0], lengths[0])
+			}
+			if offsets, lengths := tags[tagStripOffsets], tags[tagStripByteCounts]; len(offsets) == 1 && len(lengths) == 1 {
+				compression := tags[tagCompression]
+				if len(compression) == 1 && (compression[0] == 6 || compression[0] == 7) {
+					addPreview(offsets[0], lengths[0])
+				}
+			}
+
+			for _, sub := range tags[tagSubIFDs] {
+				walk(sub)
+			}
+			for _, sub := range tags[tagExifIFD] {
+				walk(sub)
+			}
+
+			offset = order.Uint32(data[offset+2+count*12:])
+		}
+	}
+	walk(order.Uint32(data[4:]))
+
+	return previews, nil
+}
+
+// tiffValues reads SHORT and LONG values of an IFD entry.
+func tiffValues(data []byte, order binary.ByteOrder, entry []byte) []uint32 {
+	typ := order.Uint16(entry[2:])
+	count := order.Uint32(entry[4:])
+
+	var size uint32
+	switch typ {
+	case 3: // SHORT
+		size = 2
+	case 4, 13: // LONG, IFD
+		size = 4
+	default:
+		return nil
+	}
+
+	if count == 0 || count > 1<<16 {
+		return nil
+	}
+
+	raw := entry[8:12]
+	if size*count > 4 {
+		offset := order.Uint32(entry[8:])
+		end := uint64(offset) + uint64(size*count)
+		if end > uint64(len(data)) {
+			return nil
+		}
+		raw = data[offset:end]
+	}
+
+	values := make([]uint32, count)
+	for i := range values {
+		if size == 2 {
+			values[i] = uint32(order.Uint16(raw[i*2:]))
+		} else {
+			values[i] = order.Uint32(raw[i*4:])
+		}
+	}
+	return values
+}
@@ -0,0 +1,153 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testTIFF builds TIFF structures for locating the previews.
+type testTIFF struct {
+	order binary.ByteOrder
+	data  []byte
+}
+
+func newTestTIFF(order binary.ByteOrder) *testTIFF {
+	file := &testTIFF{order: order, data: make([]byte, 8)}
+	if order == binary.LittleEndian {
+		copy(file.data, "II")
+	} else {
+		copy(file.data, "MM")
+	}
+	order.PutUint16(file.data[2:], 42)
+	return file
+}
+
+// blob appends data and returns its offset.
+func (file *testTIFF) blob(data []byte) uint32 {
+	offset := uint32(len(file.data))
+	file.data = append(file.data, data...)
+	if len(file.data)%2 == 1 {
+		file.data = append(file.data, 0)
+	}
+	return offset
+}
+
+// ifd appends an IFD with the LONG values of the tags and returns its offset.
+func (file *testTIFF) ifd(next uint32, tags map[uint16][]uint32) uint32 {
+	entries := make([]byte, 2+12*len(tags)+4)
+	file.order.PutUint16(entries, uint16(len(tags)))
+	i := 0
+	for tag, values := range tags {
+		entry := entries[2+12*i:]
+		file.order.PutUint16(entry[0:], tag)
+		file.order.PutUint16(entry[2:], 4)
+		file.order.PutUint32(entry[4:], uint32(len(values)))
+		if len(values) == 1 {
+			file.order.PutUint32(entry[8:], values[0])
+		} else {
+			raw := make([]byte, 4*len(values))
+			for k, value := range values {
+				file.order.PutUint32(raw[4*k:], value)
+			}
+			file.order.PutUint32(entry[8:], file.blob(raw))
+		}
+		i++
+	}
+	file.order.PutUint32(entries[len(entries)-4:], next)
+	return file.blob(entries)
+}
+
+// setNext changes the next IFD of the IFD at offset.
+func (file *testTIFF) setNext(offset, next uint32) {
+	count := uint32(file.order.Uint16(file.data[offset:]))
+	file.order.PutUint32(file.data[offset+2+12*count:], next)
+}
+
+// bytes returns the file starting with the IFD at offset.
+func (file *testTIFF) bytes(first uint32) []byte {
+	file.order.PutUint32(file.data[4:], first)
+	return file.data
+}
+
+func TestRAWPreviews(t *testing.T) {
+	large := []byte{0xFF, 0xD8, 'l', 'a', 'r', 'g', 'e'}
+	small := []byte{0xFF, 0xD8, 's'}
+
+	tests := []struct {
+		name  string
+		build func(file *testTIFF) []byte
+		want  [][]byte
+	}{
+		{"jpeg tags", func(file *testTIFF) []byte {
+			p := file.blob(large)
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {uint32(len(large))}}))
+		}, [][]byte{large}},
+		{"jpeg strip", func(file *testTIFF) []byte {
+			p := file.blob(large)
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagCompression: {7}, tagStripOffsets: {p}, tagStripByteCounts: {uint32(len(large))}}))
+		}, [][]byte{large}},
+		{"uncompressed strip", func(file *testTIFF) []byte {
+			p := file.blob(large)
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagCompression: {1}, tagStripOffsets: {p}, tagStripByteCounts: {uint32(len(large))}}))
+		}, nil},
+		{"sub ifds", func(file *testTIFF) []byte {
+			p, q := file.blob(large), file.blob(small)
+			first := file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {uint32(len(large))}})
+			second := file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {q}, tagJPEGLength: {uint32(len(small))}})
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagSubIFDs: {first, second}}))
+		}, [][]byte{large, small}},
+		{"exif ifd", func(file *testTIFF) []byte {
+			p := file.blob(small)
+			sub := file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {uint32(len(small))}})
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagExifIFD: {sub}}))
+		}, [][]byte{small}},
+		{"next ifd", func(file *testTIFF) []byte {
+			p, q := file.blob(large), file.blob(small)
+			next := file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {q}, tagJPEGLength: {uint32(len(small))}})
+			return file.bytes(file.ifd(next, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {uint32(len(large))}}))
+		}, [][]byte{large, small}},
+		{"loop", func(file *testTIFF) []byte {
+			p := file.blob(large)
+			first := file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {uint32(len(large))}})
+			file.setNext(first, first)
+			return file.bytes(first)
+		}, [][]byte{large}},
+		{"outside of the file", func(file *testTIFF) []byte {
+			p := file.blob(large)
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {1 << 20}}))
+		}, nil},
+		{"not a jpeg", func(file *testTIFF) []byte {
+			p := file.blob([]byte("raw data"))
+			return file.bytes(file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {p}, tagJPEGLength: {8}}))
+		}, nil},
+		{"truncated ifd", func(file *testTIFF) []byte {
+			data := file.bytes(file.ifd(0, map[uint16][]uint32{tagJPEGOffset: {0}, tagJPEGLength: {0}}))
+			return data[:len(data)-6]
+		}, nil},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, test := range tests {
+			previews, err := rawPreviews(test.build(newTestTIFF(order)))
+			if err != nil {
+				t.Errorf("%v %v: %v", order, test.name, err)
+				continue
+			}
+			if len(previews) != len(test.want) {
+				t.Errorf("%v %v: found %d previews, expected %d", order, test.name, len(previews), len(test.want))
+				continue
+			}
+			for i := range previews {
+				if !bytes.Equal(previews[i], test.want[i]) {
+					t.Errorf("%v %v: preview %d is %q, expected %q", order, test.name, i, previews[i], test.want[i])
+				}
+			}
+		}
+	}
+
+	for _, data := range []string{"II*", "PK\x03\x04\x14\x00\x00\x00"} {
+		if _, err := rawPreviews([]byte(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
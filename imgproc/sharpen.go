@@ -0,0 +1,29 @@
+package imgproc
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Sharpen applies an unsharp mask to m, amount is the strength of the
+// sharpening, e.g. 0.5, and radius is the sigma of the blur in pixels.
+func Sharpen(m image.Image, amount, radius float64) image.Image {
+	if amount <= 0 || radius <= 0 {
+		return m
+	}
+
+	sharp := imaging.Clone(m)
+	blurred := imaging.Blur(sharp, radius)
+	for i := range sharp.Pix {
+		// keep the alpha channel
+		if i%4 == 3 {
+			continue
+		}
+		v := float64(sharp.Pix[i])
+		v += amount * (v - float64(blurred.Pix[i]))
+		sharp.Pix[i] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+	}
+	return sharp
+}
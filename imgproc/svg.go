@@ -0,0 +1,42 @@
+package imgproc
+
+import (
+	"image"
+	"os"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+// SVGHeight is the height SVG images are rasterized at.
+var SVGHeight = 1024
+
+// DecodeSVG rasterizes the SVG to SVGHeight.
+func DecodeSVG(path string) (image.Image, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	icon, err := oksvg.ReadIconStream(file)
+	if err != nil {
+		return nil, false, err
+	}
+
+	height := SVGHeight
+	w, h := icon.ViewBox.W, icon.ViewBox.H
+	if w <= 0 || h <= 0 {
+		w, h = 1, 1
+	}
+	width := int(w * float64(height) / h)
+	if width <= 0 {
+		width = 1
+	}
+
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	icon.Draw(rasterx.NewDasher(width, height, rasterx.NewScannerGV(width, height, rgba, rgba.Bounds())), 1)
+
+	return rgba, true, nil
+}
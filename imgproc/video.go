@@ -0,0 +1,110 @@
+package imgproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var (
+	// FFmpeg is the path to ffmpeg for processing videos.
+	FFmpeg = "ffmpeg"
+	// FFprobe is the path to ffprobe for inspecting videos.
+	FFprobe = "ffprobe"
+)
+
+// Video preview settings
+const (
+	previewFrames = 30
+	previewFPS    = 10
+)
+
+// VideoFrame extracts a frame near the start of the video.
+//
+// ffmpeg applies the rotation stored in the container.
+func VideoFrame(path string) (image.Image, error) {
+	// very short clips may not have a frame at 1s, hence retry from the start
+	var lastErr error
+	for _, at := range []string{"1", "0"} {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(FFmpeg, "-v", "error",
+			"-ss", at, "-i", path,
+			"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "-")
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			lastErr = fmt.Errorf("ffmpeg %v: %v: %s", path, err, stderr.Bytes())
+			continue
+		}
+		if stdout.Len() == 0 {
+			lastErr = fmt.Errorf("ffmpeg %v: no frame at %ss", path, at)
+			continue
+		}
+		return png.Decode(&stdout)
+	}
+	return nil, lastErr
+}
+
+// TranscodeVideo converts the video to a web friendly H.264 MP4,
+// strip drops the container metadata.
+func TranscodeVideo(src, dst string, strip bool) error {
+	args := []string{"-c:v", "libx264", "-preset", "slow", "-crf", "23", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-movflags", "+faststart"}
+	if strip {
+		args = append(args, "-map_metadata", "-1")
+	}
+	return ffmpeg(src, dst, args...)
+}
+
+// ffmpeg converts src to dst with the output options in args,
+// dst is replaced once the conversion succeeds.
+func ffmpeg(src, dst string, args ...string) error {
+	return WriteAtomic(dst, func(tmp string) error {
+		args := append([]string{"-v", "error", "-y", "-i", src}, args...)
+		cmd := exec.Command(FFmpeg, append(args, tmp)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg %v: %v: %s", src, err, out)
+		}
+		return nil
+	})
+}
+
+// VideoDuration returns the duration of the video in seconds.
+func VideoDuration(path string) (float64, error) {
+	cmd := exec.Command(FFprobe, "-v", "error",
+		"-show_entries", "format=duration", "-of", "csv=p=0", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe %v: %v", path, err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
+// VideoPreview creates a short looping animated WebP from frames
+// sampled evenly across the whole video.
+func VideoPreview(src, dst string, height int) error {
+	duration, err := VideoDuration(src)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return fmt.Errorf("%v: unknown duration", src)
+	}
+
+	filter := fmt.Sprintf("fps=%f,scale=-2:%d,setpts=N/(%d*TB)",
+		previewFrames/duration, height, previewFPS)
+	return ffmpeg(src, dst,
+		"-vf", filter, "-r", strconv.Itoa(previewFPS), "-frames:v", strconv.Itoa(previewFrames),
+		"-an", "-loop", "0", "-c:v", "libwebp", "-quality", "60")
+}
+
+// CopyVideo copies the video container, strip drops the metadata.
+func CopyVideo(src, dst string, strip bool) error {
+	if !strip {
+		return CopyFile(src, dst)
+	}
+	return ffmpeg(src, dst, "-map", "0", "-map_metadata", "-1", "-c", "copy")
+}
@@ -0,0 +1,98 @@
+package imgproc
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VIPSPath is the path to the vips command line tool.
+var VIPSPath = "vips"
+
+// VIPS is a processor that uses libvips, which shrinks JPEGs while decoding
+// and is several times faster than Go for large photos.
+//
+// The renditions that don't need processing in Go are rendered by vips
+// straight from the source into the target format, see Render. Otherwise
+// vips decodes and shrinks the source and the rest is done with Go.
+type VIPS struct{ Go }
+
+// Load decodes the image at path with vips shrinking it to size,
+// formats handled by other external tools are decoded with Go.
+func (VIPS) Load(path string, size int) (image.Image, error) {
+	if IsExternal(path) {
+		return Load(path)
+	}
+
+	dir, err := ioutil.TempDir("", "gallery-vips")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "image.png")
+	if err := vipsThumbnail(path, out+"[compression=1]", size); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(out)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// Renders returns whether vips can render the source at path into the format.
+func (VIPS) Renders(path, format string) bool {
+	return !IsExternal(path) && vipsOptions(format, 0) != ""
+}
+
+// Render scales the source at path down to size and encodes it to out
+// in the format, the metadata isn't kept.
+func (VIPS) Render(path, out string, size int, format string, quality int) error {
+	options := vipsOptions(format, quality)
+	if options == "" {
+		return fmt.Errorf("vips can't render %q", format)
+	}
+	return vipsThumbnail(path, out+options, size)
+}
+
+// vipsOptions returns the vips save options for the format,
+// empty when vips doesn't render it.
+func vipsOptions(format string, quality int) string {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		if quality <= 0 {
+			quality = JPEGQuality
+		}
+		return "[Q=" + strconv.Itoa(quality) + ",optimize_coding,strip]"
+	case "png":
+		return "[strip]"
+	case "webp":
+		return "[Q=" + strconv.Itoa(WebPQuality) + ",strip]"
+	case "webp-lossless":
+		return "[lossless,strip]"
+	}
+	return ""
+}
+
+// vipsThumbnail shrinks the source at path to size and saves it to out,
+// which may contain the save options.
+func vipsThumbnail(path, out string, size int) error {
+	// the width is unbounded, like Downscale the size limits the height;
+	// vips thumbnail rotates the image upright and converts it to sRGB
+	cmd := exec.Command(VIPSPath, "thumbnail", path, out, "100000",
+		"--height", strconv.Itoa(size), "--size", "down", "--export-profile", "srgb")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("vips %v: %v: %s", path, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package imgproc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVIPSRender(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "vips")
+	err := ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+args+"\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(path string) { VIPSPath = path }(VIPSPath)
+	VIPSPath = script
+
+	tests := []struct {
+		format  string
+		quality int
+		options string
+	}{
+		{"jpg", 80, "[Q=80,optimize_coding,strip]"},
+		{"jpg", 0, "[Q=93,optimize_coding,strip]"},
+		{"png", 0, "[strip]"},
+		{"webp", 0, "[Q=80,strip]"},
+		{"webp-lossless", 0, "[lossless,strip]"},
+		{"avif", 0, ""},
+	}
+	for _, test := range tests {
+		os.Remove(args)
+		out := filepath.Join(dir, "out"+FormatExt(test.format))
+		err := VIPS{}.Render("photo.jpg", out, 256, test.format, test.quality)
+		if test.options == "" {
+			if err == nil || (VIPS{}).Renders("photo.jpg", test.format) {
+				t.Errorf("%v: rendered", test.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.format, err)
+			continue
+		}
+		if !(VIPS{}).Renders("photo.jpg", test.format) {
+			t.Errorf("%v: not rendered", test.format)
+		}
+
+		data, err := ioutil.ReadFile(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "thumbnail photo.jpg " + out + test.options + " 100000 --height 256 --size down --export-profile srgb"
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%v: vips %v, expected %v", test.format, got, want)
+		}
+	}
+
+	if (VIPS{}).Renders("photo.heic", "jpg") {
+		t.Errorf("sources of external decoders rendered")
+	}
+}
@@ -0,0 +1,36 @@
+// Package async runs the iterations of a loop in parallel.
+package async
+
+import "sync"
+
+// Iter calls fn for each i in [0, n) using at most procs goroutines
+// and returns once all the calls have finished.
+func Iter(n, procs int, fn func(i int)) {
+	if procs < 1 {
+		procs = 1
+	}
+	if procs > n {
+		procs = n
+	}
+
+	var mu sync.Mutex
+	next := 0
+	var wg sync.WaitGroup
+	wg.Add(procs)
+	for p := 0; p < procs; p++ {
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				next++
+				mu.Unlock()
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package async
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestIter(t *testing.T) {
+	tests := []struct{ n, procs int }{{0, 4}, {1, 4}, {10, 1}, {10, 3}, {100, 0}, {3, 10}}
+	for _, test := range tests {
+		calls := make([]int32, test.n)
+		var running, peak int32
+		Iter(test.n, test.procs, func(i int) {
+			now := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&peak)
+				if now <= max || atomic.CompareAndSwapInt32(&peak, max, now) {
+					break
+				}
+			}
+			atomic.AddInt32(&calls[i], 1)
+			atomic.AddInt32(&running, -1)
+		})
+		for i, count := range calls {
+			if count != 1 {
+				t.Errorf("Iter(%d, %d) called %d %d times", test.n, test.procs, i, count)
+			}
+		}
+		if limit := int32(test.procs); limit > 0 && peak > limit {
+			t.Errorf("Iter(%d, %d) ran %d calls at once", test.n, test.procs, peak)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package gallery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// XMP contains the interesting fields of an XMP packet.
+type XMP struct {
+	Subject     []string
+	Title       string
+	Description string
+	// Rating is the star rating from 0 to 5, -1 marks rejected photos.
+	Rating int
+}
+
+// ReadKeywords reads IPTC keywords and XMP subjects of the source,
+// including the ones in an .xmp sidecar.
+func ReadKeywords(path string) []string {
+	return ReadXMP(path).Subject
+}
+
+// ReadXMP reads the XMP of the source, the fields of an .xmp sidecar
+// override the embedded ones. The subjects include the IPTC keywords.
+func ReadXMP(path string) XMP {
+	var keywords []string
+	var result XMP
+	merge := func(xmp XMP) {
+		keywords = append(keywords, xmp.Subject...)
+		if xmp.Title != "" {
+			result.Title = xmp.Title
+		}
+		if xmp.Description != "" {
+			result.Description = xmp.Description
+		}
+		if xmp.Rating != 0 {
+			result.Rating = xmp.Rating
+		}
+	}
+
+	// videos can be huge and their metadata isn't read
+	if SourceKind(path) != KindVideo {
+		if file, err := Files.Open(path); err == nil {
+			packets, iptc := embeddedXMP(file)
+			file.Close()
+			for _, packet := range packets {
+				merge(ParseXMP(packet))
+			}
+			keywords = append(keywords, iptc...)
+		}
+	}
+
+	// darktable uses IMG_1234.CR2.xmp, Lightroom uses IMG_1234.xmp
+	for _, sidecar := range []string{path + ".xmp", ReplaceExt(path, ".xmp")} {
+		if data, err := readFile(sidecar); err == nil {
+			merge(ParseXMP(data))
+			break
+		}
+	}
+
+	result.Subject = uniqueStrings(keywords)
+	return result
+}
+
+// Defaults fills the title, caption and rating the sidecar doesn't set.
+func (xmp XMP) Defaults(sidecar Sidecar) Sidecar {
+	if sidecar.Title == "" {
+		sidecar.Title = xmp.Title
+	}
+	if sidecar.Caption == "" {
+		sidecar.Caption = xmp.Description
+	}
+	if sidecar.Rating == 0 {
+		sidecar.Rating = xmp.Rating
+	}
+	return sidecar
+}
+
+var (
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+)
+
+// xmpSearchLimit is how much of a file other than JPEG is searched
+// for an embedded XMP packet, the packets are written near the start.
+const xmpSearchLimit = 4 << 20
+
+// embeddedXMP reads the XMP packets and the IPTC keywords of an image.
+// Only the JPEG header or the start of other files is read.
+func embeddedXMP(r io.Reader) (packets [][]byte, keywords []string) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err != nil || magic[0] != 0xFF || magic[1] != 0xD8 {
+		data, _ := ioutil.ReadAll(io.LimitReader(br, xmpSearchLimit))
+		if packet := findXMP(data); packet != nil {
+			packets = append(packets, packet)
+		}
+		return packets, nil
+	}
+
+	for _, segment := range jpegSegments(br, 0xE1, 0xED) {
+		switch {
+		case segment.marker == 0xE1 && bytes.HasPrefix(segment.data, xmpHeader):
+			packets = append(packets, segment.data[len(xmpHeader):])
+		case segment.marker == 0xED && bytes.HasPrefix(segment.data, photoshopHeader):
+			keywords = append(keywords, iptcKeywords(segment.data[len(photoshopHeader):])...)
+		}
+	}
+	return packets, keywords
+}
+
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// jpegSegments reads the segments with the markers before the image data,
+// the other segments are skipped.
+func jpegSegments(r *bufio.Reader, markers ...byte) []jpegSegment {
+	var segments []jpegSegment
+	if _, err := r.Discard(2); err != nil {
+		return nil
+	}
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			break
+		}
+		marker := header[1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			break
+		}
+		if !bytes.Contains(markers, []byte{marker}) {
+			if _, err := r.Discard(length - 2); err != nil {
+				break
+			}
+			continue
+		}
+		data := make([]byte, length-2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		segments = append(segments, jpegSegment{marker, data})
+	}
+	return segments
+}
+
+// findXMP finds an embedded XMP packet in arbitrary file data.
+func findXMP(data []byte) []byte {
+	start := bytes.Index(data, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+	end := bytes.Index(data[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	return data[start : start+end+len("</x:xmpmeta>")]
+}
+
+const (
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsXMP = "http://ns.adobe.com/xap/1.0/"
+)
+
+// ParseXMP extracts the fields from an XMP packet.
+func ParseXMP(packet []byte) XMP {
+	var result XMP
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	decoder.Strict = false
+
+	var stack []xml.Name
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, token.Name)
+			text.Reset()
+			// simple properties are usually attributes of rdf:Description
+			for _, attr := range token.Attr {
+				if attr.Name.Space == nsXMP && attr.Name.Local == "Rating" {
+					result.Rating = parseRating(attr.Value)
+				}
+			}
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			value := strings.TrimSpace(text.String())
+			isItem := token.Name.Space == nsRDF && token.Name.Local == "li"
+			switch {
+			case value == "":
+			case isItem && inside(stack, nsDC, "subject"):
+				result.Subject = append(result.Subject, value)
+			// language alternatives list the default language first
+			case isItem && inside(stack, nsDC, "title") && result.Title == "":
+				result.Title = value
+			case isItem && inside(stack, nsDC, "description") && result.Description == "":
+				result.Description = value
+			case token.Name.Space == nsXMP && token.Name.Local == "Rating":
+				result.Rating = parseRating(value)
+			}
+			stack = stack[:len(stack)-1]
+			text.Reset()
+		}
+	}
+
+	return result
+}
+
+// parseRating parses a star rating, invalid values are treated as unrated.
+func parseRating(value string) int {
+	rating, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || rating < -1 || rating > 5 {
+		return 0
+	}
+	return int(rating)
+}
+
+// inside returns whether the element stack contains the element.
+func inside(stack []xml.Name, space, local string) bool {
+	for _, name := range stack {
+		if name.Space == space && name.Local == local {
+			return true
+		}
+	}
+	return false
+}
+
+// iptcKeywords extracts the IPTC keywords (2:25) from Photoshop image resources.
+func iptcKeywords(data []byte) []string {
+	var keywords []string
+
+	p := 0
+	for p+12 <= len(data) && bytes.Equal(data[p:p+4], []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(data[p+4:])
+		p += 6
+
+		// pascal string name padded to even length
+		namelen := int(data[p])
+		p += (1 + namelen + 1) &^ 1
+		if p+4 > len(data) {
+			break
+		}
+
+		size := int(binary.BigEndian.Uint32(data[p:]))
+		p += 4
+		if size < 0 || p+size > len(data) {
+			break
+		}
+
+		if id == 0x0404 {
+			keywords = append(keywords, iptcDatasets(data[p:p+size], 2, 25)...)
+		}
+		p += (size + 1) &^ 1
+	}
+
+	return keywords
+}
+
+// iptcDatasets returns the values of the specified IPTC datasets.
+func iptcDatasets(data []byte, record, dataset byte) []string {
+	var values []string
+	p := 0
+	for p+5 <= len(data) && data[p] == 0x1C {
+		rec, set := data[p+1], data[p+2]
+		size := int(binary.BigEndian.Uint16(data[p+3:]))
+		p += 5
+		if size&0x8000 != 0 || p+size > len(data) {
+			// extended datasets are not used for keywords
+			break
+		}
+		if rec == record && set == dataset {
+			values = append(values, strings.TrimSpace(string(data[p:p+size])))
+		}
+		p += size
+	}
+	return values
+}
+
+// uniqueStrings removes empty and case-insensitive duplicate values.
+func uniqueStrings(values []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, value)
+	}
+	return result
+}
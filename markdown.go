@@ -0,0 +1,34 @@
+package gallery
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"github.com/yuin/goldmark"
+)
+
+// descriptionFiles are markdown files that describe the gallery, in order of preference.
+var descriptionFiles = []string{"description.md", "README.md"}
+
+// LoadDescription loads the markdown description of the gallery in dir.
+func LoadDescription(dir string) (string, error) {
+	for _, name := range descriptionFiles {
+		data, err := readFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		return string(data), err
+	}
+	return "", nil
+}
+
+// RenderMarkdown converts markdown to HTML, raw HTML in the source is omitted.
+func RenderMarkdown(source string) (template.HTML, error) {
+	var buffer bytes.Buffer
+	if err := goldmark.Convert([]byte(source), &buffer); err != nil {
+		return "", err
+	}
+	return template.HTML(buffer.String()), nil
+}
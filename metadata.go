@@ -0,0 +1,140 @@
+package gallery
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Metadata contains the camera information of an image.
+type Metadata struct {
+	Camera      string
+	Lens        string
+	FocalLength string
+	Aperture    string
+	Shutter     string
+	ISO         int
+	Taken       time.Time
+	Description string
+	// Location is where the image was taken, nil when unknown.
+	Location *Location
+	// Rating is the star rating from 0 to 5 written by Windows and
+	// some cameras, XMP ratings take precedence.
+	Rating int
+}
+
+// Location is a GPS position in degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// IsZero returns whether no metadata was found.
+func (meta *Metadata) IsZero() bool {
+	return meta == nil || *meta == Metadata{}
+}
+
+// ReadMetadata extracts the camera information from EXIF,
+// it returns nil when the file doesn't contain EXIF.
+func ReadMetadata(path string) *Metadata {
+	f, err := Files.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil || x == nil {
+		return nil
+	}
+	return ExifMetadata(x)
+}
+
+// exifRating is the Windows rating tag, which goexif doesn't decode.
+const exifRating exif.FieldName = "Rating"
+
+// ExifMetadata extracts the camera information from decoded EXIF.
+func ExifMetadata(x *exif.Exif) *Metadata {
+	meta := &Metadata{}
+
+	maker, model := exifString(x, exif.Make), exifString(x, exif.Model)
+	if maker != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		meta.Camera = strings.TrimSpace(maker + " " + model)
+	} else {
+		meta.Camera = model
+	}
+	meta.Lens = exifString(x, exif.LensModel)
+	meta.Description = exifString(x, exif.ImageDescription)
+	if lat, long, err := x.LatLong(); err == nil && !(lat == 0 && long == 0) {
+		meta.Location = &Location{Latitude: lat, Longitude: long}
+	}
+
+	if v, ok := exifFloat(x, exif.FocalLength); ok && v > 0 {
+		meta.FocalLength = fmt.Sprintf("%g mm", math.Round(v*10)/10)
+	}
+	if v, ok := exifFloat(x, exif.FNumber); ok && v > 0 {
+		meta.Aperture = fmt.Sprintf("f/%g", math.Round(v*10)/10)
+	}
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && num > 0 && den > 0 {
+			if num < den {
+				meta.Shutter = fmt.Sprintf("1/%d s", int64(math.Round(float64(den)/float64(num))))
+			} else {
+				meta.Shutter = fmt.Sprintf("%g s", math.Round(float64(num)/float64(den)*10)/10)
+			}
+		}
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			meta.ISO = iso
+		}
+	}
+	if tag, err := x.Get(exif.DateTimeOriginal); err == nil {
+		if s, err := tag.StringVal(); err == nil {
+			if t, err := time.ParseInLocation("2006:01:02 15:04:05", strings.TrimSpace(s), time.Local); err == nil {
+				meta.Taken = t
+			}
+		}
+	}
+
+	if len(x.Tiff.Dirs) > 0 {
+		x.LoadTags(x.Tiff.Dirs[0], map[uint16]exif.FieldName{0x4746: exifRating}, false)
+		if tag, err := x.Get(exifRating); err == nil {
+			if rating, err := tag.Int(0); err == nil && rating >= 0 && rating <= 5 {
+				meta.Rating = rating
+			}
+		}
+	}
+
+	if meta.IsZero() {
+		return nil
+	}
+	return meta
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(s, "\x00"))
+}
+
+func exifFloat(x *exif.Exif, name exif.FieldName) (float64, bool) {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0, false
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
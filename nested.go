@@ -0,0 +1,81 @@
+package gallery
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LinkGalleries connects nested galleries to their parents,
+// directories without images get a gallery listing their children.
+func LinkGalleries(imagesDir string, galleries map[string]*Gallery) error {
+	var pending []*Gallery
+	for _, gallery := range galleries {
+		pending = append(pending, gallery)
+	}
+
+	for len(pending) > 0 {
+		gallery := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		dir := filepath.Dir(gallery.Path)
+		if gallery.Path == imagesDir || dir == imagesDir || dir == "." {
+			continue
+		}
+
+		parent, ok := galleries[strings.ToLower(dir)]
+		if !ok {
+			var err error
+			parent, err = New(imagesDir, dir)
+			if err != nil {
+				return err
+			}
+			galleries[strings.ToLower(dir)] = parent
+			pending = append(pending, parent)
+		}
+
+		gallery.Parent = parent
+		parent.Children = append(parent.Children, gallery)
+	}
+
+	for _, gallery := range galleries {
+		sort.Slice(gallery.Children, func(i, k int) bool {
+			return strings.ToLower(gallery.Children[i].Path) < strings.ToLower(gallery.Children[k].Path)
+		})
+	}
+	return nil
+}
+
+// Breadcrumbs returns the galleries from the top-level gallery to this gallery.
+func (gallery *Gallery) Breadcrumbs() []*Gallery {
+	var trail []*Gallery
+	for g := gallery; g != nil; g = g.Parent {
+		trail = append([]*Gallery{g}, trail...)
+	}
+	return trail
+}
+
+// ChildCover returns the cover of the first nested gallery that has one.
+func (gallery *Gallery) ChildCover() *Image {
+	for _, child := range gallery.PublishedChildren() {
+		if child.Cover != nil {
+			return child.Cover
+		}
+		if cover := child.ChildCover(); cover != nil {
+			return cover
+		}
+	}
+	return nil
+}
+
+// PublishedChildren returns the nested galleries that are published,
+// unlisted galleries are only listed in unlisted galleries.
+func (gallery *Gallery) PublishedChildren() []*Gallery {
+	var children []*Gallery
+	for _, child := range gallery.Children {
+		if child.Published() && (!child.Unlisted() || gallery.Unlisted()) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
@@ -0,0 +1,82 @@
+package gallery
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+// ValidOrganize returns an error when mode is not known.
+func ValidOrganize(mode string) error {
+	switch mode {
+	case "directory", "date":
+		return nil
+	}
+	return fmt.Errorf("unknown organize mode %q", mode)
+}
+
+// OrganizeByDate regroups the images of galleries by the capture date
+// into year and month galleries, e.g. "2023/07". Unlisted galleries are
+// kept as they are.
+func OrganizeByDate(imagesDir string, galleries map[string]*Gallery) map[string]*Gallery {
+	organized := map[string]*Gallery{}
+	var images []*Image
+	for key, gallery := range galleries {
+		if gallery.Unlisted() {
+			if gallery.Parent != nil && !gallery.Parent.Unlisted() {
+				gallery.Parent = nil
+			}
+			organized[key] = gallery
+			continue
+		}
+		images = append(images, gallery.Images...)
+	}
+
+	async.Iter(len(images), workers(), func(i int) {
+		images[i].Metadata = ReadMetadata(images[i].Raw)
+	})
+
+	for _, image := range images {
+		date := image.Date()
+		year := date.Format("2006")
+		month := filepath.Join(year, date.Format("01"))
+
+		yearGallery, ok := organized[year]
+		if !ok {
+			yearGallery = dateGallery(imagesDir, year, year, date.Year(), 1)
+			organized[year] = yearGallery
+		}
+
+		monthGallery, ok := organized[month]
+		if !ok {
+			monthGallery = dateGallery(imagesDir, month, date.Format("January 2006"), date.Year(), date.Month())
+			monthGallery.Parent = yearGallery
+			yearGallery.Children = append(yearGallery.Children, monthGallery)
+			organized[month] = monthGallery
+		}
+
+		image.Unbound = filepath.Join(month, filepath.Base(image.Unbound))
+		image.Path = filepath.Join(ImagesDir, image.Unbound)
+		monthGallery.Images = append(monthGallery.Images, image)
+	}
+
+	for _, gallery := range organized {
+		sort.Slice(gallery.Children, func(i, k int) bool {
+			return gallery.Children[i].Date.After(gallery.Children[k].Date)
+		})
+	}
+	return organized
+}
+
+func dateGallery(imagesDir, unbound, title string, year int, month time.Month) *Gallery {
+	return &Gallery{
+		Name:    filepath.Base(unbound),
+		Path:    filepath.Join(imagesDir, unbound),
+		Unbound: unbound,
+		Title:   title,
+		Date:    time.Date(year, month, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
@@ -0,0 +1,85 @@
+package gallery
+
+import (
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// Page is a single page of a paginated gallery.
+type Page struct {
+	Number int
+	Count  int
+	Images []*Image
+
+	// Prev and Next are links to the neighbouring pages,
+	// they are empty on the first and the last page.
+	Prev string
+	Next string
+}
+
+// PageNumberLink returns the link to the n-th page of the gallery,
+// the first page is the gallery index.
+func (gallery *Gallery) PageNumberLink(n int) string {
+	if n <= 1 {
+		return gallery.PageLink() + "/"
+	}
+	return path.Join(gallery.PageLink(), "page", strconv.Itoa(n)) + "/"
+}
+
+// PageNumberFile returns the output file of the n-th page of the gallery.
+func (gallery *Gallery) PageNumberFile(n int) string {
+	if n <= 1 {
+		return filepath.Join(gallery.Unbound, "index.html")
+	}
+	return filepath.Join(gallery.Unbound, "page", strconv.Itoa(n), "index.html")
+}
+
+// Paginate splits the gallery grid images into pages of at most perPage images,
+// when perPage is not positive all images are on a single page.
+func (gallery *Gallery) Paginate(perPage int) []*Page {
+	images := gallery.GridImages()
+	if perPage <= 0 || len(images) <= perPage {
+		return []*Page{{Number: 1, Count: 1, Images: images}}
+	}
+
+	count := (len(images) + perPage - 1) / perPage
+	pages := make([]*Page, 0, count)
+	for i := 0; i < count; i++ {
+		low, high := i*perPage, (i+1)*perPage
+		if high > len(images) {
+			high = len(images)
+		}
+
+		page := &Page{
+			Number: i + 1,
+			Count:  count,
+			Images: images[low:high],
+		}
+		if page.Number > 1 {
+			page.Prev = gallery.PageNumberLink(page.Number - 1)
+		}
+		if page.Number < count {
+			page.Next = gallery.PageNumberLink(page.Number + 1)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// ImagePage returns the link to the gallery page containing the image,
+// or the first image of its stack.
+func (gallery *Gallery) ImagePage(image *Image, perPage int) string {
+	if perPage <= 0 {
+		return gallery.PageNumberLink(1)
+	}
+	if image.StackTop != nil {
+		image = image.StackTop
+	}
+	for i, x := range gallery.GridImages() {
+		if x == image {
+			return gallery.PageNumberLink(i/perPage + 1)
+		}
+	}
+	return gallery.PageNumberLink(1)
+}
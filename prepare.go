@@ -0,0 +1,97 @@
+package gallery
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/egonelbre/gallery/internal/async"
+)
+
+// Jobs is the number of files processed concurrently, zero uses all CPUs.
+var Jobs = 0
+
+// workers returns the number of concurrent workers.
+func workers() int {
+	if Jobs > 0 {
+		return Jobs
+	}
+	return runtime.GOMAXPROCS(-1)
+}
+
+// Prepare reads the metadata and sidecars of the images,
+// orders them and finds the cover. The images are sorted using
+// order unless the gallery configures it. The returned problems
+// don't prevent publishing the gallery.
+func Prepare(gallery *Gallery, order string) []error {
+	var mu sync.Mutex
+	problems := Disambiguate(gallery)
+	report := func(err error) {
+		mu.Lock()
+		problems = append(problems, err)
+		mu.Unlock()
+	}
+
+	async.Iter(len(gallery.Images), workers(), func(i int) {
+		image := gallery.Images[i]
+		if image.Metadata == nil {
+			image.Metadata = ReadMetadata(image.Raw)
+		}
+		xmp := ReadXMP(image.Raw)
+		image.Tags = xmp.Subject
+
+		sidecar, err := LoadSidecar(image.Raw)
+		if err != nil {
+			report(err)
+		}
+		ApplySidecar(image, xmp.Defaults(sidecar))
+		if image.Rating == 0 && image.Metadata != nil {
+			image.Rating = image.Metadata.Rating
+		}
+	})
+
+	all := gallery.Images
+	if min := gallery.MinimumRating(); min >= 0 {
+		gallery.Images = FilterRating(gallery.Images, min)
+	}
+
+	SortImages(gallery.Images, gallery.SortOrder(order))
+	if manual, err := LoadOrder(gallery.Path); err != nil {
+		report(err)
+	} else {
+		for _, name := range manual {
+			if !containsImage(all, name) {
+				report(fmt.Errorf("%v: ordered image %q not found", gallery.Path, name))
+			}
+		}
+		ApplyOrder(gallery.Images, manual)
+	}
+
+	gallery.Cover = FindCover(gallery)
+	if name := gallery.Config.Cover; name != "" && !containsImage(all, name) {
+		report(fmt.Errorf("%v: cover %q not found", gallery.Path, name))
+	}
+
+	return problems
+}
+
+// FilterRating returns the images rated at least min.
+func FilterRating(images []*Image, min int) []*Image {
+	var rated []*Image
+	for _, image := range images {
+		if image.Rating >= min {
+			rated = append(rated, image)
+		}
+	}
+	return rated
+}
+
+// containsImage returns whether one of the images matches name.
+func containsImage(images []*Image, name string) bool {
+	for _, image := range images {
+		if image.MatchesName(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package gallery
+
+import "testing"
+
+func TestMinimumRating(t *testing.T) {
+	rating := func(n int) *int { return &n }
+
+	parent := &Gallery{}
+	child := &Gallery{Parent: parent}
+	if got := child.MinimumRating(); got != MinRating {
+		t.Errorf("unconfigured minimum is %d, expected %d", got, MinRating)
+	}
+
+	parent.Config.MinRating = rating(3)
+	if got := child.MinimumRating(); got != 3 {
+		t.Errorf("inherited minimum is %d, expected 3", got)
+	}
+
+	child.Config.MinRating = rating(0)
+	if got := child.MinimumRating(); got != 0 {
+		t.Errorf("overridden minimum is %d, expected 0", got)
+	}
+}
+
+func TestFilterRating(t *testing.T) {
+	images := []*Image{{Name: "rejected", Rating: -1}, {Name: "unrated"}, {Name: "good", Rating: 3}, {Name: "best", Rating: 5}}
+
+	tests := []struct {
+		min  int
+		want []string
+	}{
+		{-1, []string{"rejected", "unrated", "good", "best"}},
+		{0, []string{"unrated", "good", "best"}},
+		{3, []string{"good", "best"}},
+		{5, []string{"best"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, image := range FilterRating(images, test.min) {
+			got = append(got, image.Name)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("FilterRating(%d) = %v, expected %v", test.min, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("FilterRating(%d) = %v, expected %v", test.min, got, test.want)
+				break
+			}
+		}
+	}
+}
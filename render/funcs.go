@@ -0,0 +1,91 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/egonelbre/gallery"
+)
+
+// Funcs are the helpers available in all templates.
+var Funcs = template.FuncMap{
+	"date":    FormatDate,
+	"bytes":   HumanSize,
+	"exif":    FormatExif,
+	"slugify": gallery.TagSlug,
+	"srcset":  Srcset,
+	"urljoin": JoinURL,
+
+	// features of the site, replaced when calling Templates
+	"asset":    func(name string) string { return path.Join("/", name) },
+	"pwa":      func() bool { return false },
+	"lightbox": func() bool { return false },
+}
+
+// FormatDate formats t using layout, zero time results in an empty string.
+func FormatDate(layout string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// HumanSize formats size in bytes using binary units, e.g. "1.5 MB".
+func HumanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatExif summarizes the camera settings on a single line,
+// e.g. "X-T3 · 35 mm · f/2 · 1/250 s · ISO 200".
+func FormatExif(meta *gallery.Metadata) string {
+	if meta.IsZero() {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{meta.Camera, meta.FocalLength, meta.Aperture, meta.Shutter} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if meta.ISO > 0 {
+		parts = append(parts, fmt.Sprintf("ISO %d", meta.ISO))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Srcset formats the renditions of image for the srcset attribute,
+// skipping the ones wider than max when it's given.
+func Srcset(image *gallery.Image, max ...int) string {
+	if len(max) > 0 {
+		return image.SrcsetUpTo(max[0])
+	}
+	return image.Srcset()
+}
+
+// JoinURL joins the path elements to base, which may be
+// an absolute URL or a path.
+func JoinURL(base string, elems ...string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return path.Join(append([]string{base}, elems...)...)
+	}
+	trailing := len(elems) > 0 && strings.HasSuffix(elems[len(elems)-1], "/")
+	u.Path = path.Join(append([]string{"/", u.Path}, elems...)...)
+	if trailing && u.Path != "/" {
+		u.Path += "/"
+	}
+	return u.String()
+}
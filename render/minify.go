@@ -0,0 +1,93 @@
+package render
+
+import "bytes"
+
+// MinifyCSS removes comments and unnecessary whitespace.
+func MinifyCSS(data []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := quoteEnd(data, i)
+			out.Write(data[i:end])
+			i = end - 1
+			space = false
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		default:
+			if space && out.Len() > 0 && !isCSSPunct(out.Bytes()[out.Len()-1]) && !isCSSPunct(c) {
+				out.WriteByte(' ')
+			}
+			if c == '}' && out.Len() > 0 && out.Bytes()[out.Len()-1] == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			out.WriteByte(c)
+			space = false
+		}
+	}
+	return out.Bytes()
+}
+
+// isCSSPunct returns whether whitespace around c can be removed.
+func isCSSPunct(c byte) bool {
+	return c == '{' || c == '}' || c == ';' || c == ','
+}
+
+// MinifyJS removes comments, indentation and empty lines.
+// Line breaks are kept to avoid changing semicolon insertion.
+func MinifyJS(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			end := quoteEnd(data, i)
+			out.Write(data[i:end])
+			i = end - 1
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(out.Bytes(), []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// quoteEnd returns the index after the string starting at data[start].
+func quoteEnd(data []byte, start int) int {
+	quote := data[start]
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,45 @@
+// Package render executes the site templates, the default theme
+// is embedded and its templates can be overridden one by one.
+package render
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"path/filepath"
+)
+
+//go:embed theme
+var theme embed.FS
+
+// Theme contains the default templates and static directories.
+var Theme = mustSub(theme, "theme")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Templates parses the default theme and then the templates in dir,
+// which replace the same-named ones. The funcs are available in
+// addition to Funcs.
+func Templates(dir string, funcs template.FuncMap) (*template.Template, error) {
+	t, err := template.New("").Funcs(Funcs).Funcs(funcs).ParseFS(Theme, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	pattern := filepath.Join(dir, "*.html")
+	if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+		return t.ParseGlob(pattern)
+	}
+	return t, nil
+}
+
+// HasThemeDir returns whether the default theme contains dir.
+func HasThemeDir(dir string) bool {
+	info, err := fs.Stat(Theme, filepath.ToSlash(dir))
+	return err == nil && info.IsDir()
+}
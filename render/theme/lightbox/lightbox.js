@@ -0,0 +1,85 @@
+// Lightbox opens gallery thumbnails in an overlay,
+// links without data-lightbox keep opening the image page.
+(function(){
+	var links = Array.prototype.slice.call(document.querySelectorAll("a[data-lightbox]"));
+	if(links.length == 0) return;
+
+	var overlay = document.createElement("div");
+	overlay.className = "lightbox";
+	overlay.hidden = true;
+	overlay.innerHTML =
+		'<img class="lightbox-image" alt="">' +
+		'<div class="lightbox-caption"><a class="lightbox-page"></a></div>' +
+		'<button class="lightbox-prev" aria-label="Previous">🡄</button>' +
+		'<button class="lightbox-next" aria-label="Next">🡆</button>' +
+		'<button class="lightbox-close" aria-label="Close">✕</button>';
+	document.body.appendChild(overlay);
+
+	var image = overlay.querySelector(".lightbox-image");
+	var page = overlay.querySelector(".lightbox-page");
+	var current = -1;
+
+	function show(index){
+		current = (index + links.length) % links.length;
+		var link = links[current];
+		image.removeAttribute("srcset");
+		image.src = link.dataset.lightbox;
+		if(link.dataset.srcset){
+			image.srcset = link.dataset.srcset;
+			image.sizes = "100vw";
+		}
+		if(link.dataset.width){
+			image.width = link.dataset.width;
+			image.height = link.dataset.height;
+		}
+		image.alt = link.dataset.title || "";
+		page.textContent = link.dataset.title || "";
+		page.href = link.href;
+		overlay.hidden = false;
+		document.body.classList.add("lightbox-open");
+	}
+
+	function close(){
+		overlay.hidden = true;
+		current = -1;
+		document.body.classList.remove("lightbox-open");
+	}
+
+	links.forEach(function(link, index){
+		link.addEventListener("click", function(ev){
+			if(ev.button != 0 || ev.ctrlKey || ev.metaKey || ev.shiftKey || ev.altKey) return;
+			ev.preventDefault();
+			show(index);
+		});
+	});
+
+	overlay.querySelector(".lightbox-prev").addEventListener("click", function(){ show(current - 1); });
+	overlay.querySelector(".lightbox-next").addEventListener("click", function(){ show(current + 1); });
+	overlay.querySelector(".lightbox-close").addEventListener("click", close);
+	overlay.addEventListener("click", function(ev){
+		if(ev.target == overlay) close();
+	});
+
+	document.addEventListener("keydown", function(ev){
+		if(current < 0) return;
+		switch(ev.key){
+		case "ArrowLeft": show(current - 1); break;
+		case "ArrowRight": show(current + 1); break;
+		case "Escape": close(); break;
+		default: return;
+		}
+		ev.preventDefault();
+	});
+
+	var startX = 0, startY = 0;
+	overlay.addEventListener("touchstart", function(ev){
+		startX = ev.changedTouches[0].clientX;
+		startY = ev.changedTouches[0].clientY;
+	}, {passive: true});
+	overlay.addEventListener("touchend", function(ev){
+		var dx = ev.changedTouches[0].clientX - startX;
+		var dy = ev.changedTouches[0].clientY - startY;
+		if(Math.abs(dx) < 50 || Math.abs(dx) < Math.abs(dy)) return;
+		show(dx < 0 ? current + 1 : current - 1);
+	});
+})();
\ No newline at end of file
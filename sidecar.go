@@ -0,0 +1,115 @@
+package gallery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Sidecar contains per image information from IMG_1234.yaml.
+type Sidecar struct {
+	Title   string   `yaml:"title,omitempty"`
+	Caption string   `yaml:"caption,omitempty"`
+	Tags    []string `yaml:"tags,omitempty"`
+	// Date overrides when the photo was taken, formatted as SidecarDate.
+	Date string `yaml:"date,omitempty"`
+	// Rating is the star rating from 0 to 5, -1 marks rejected photos.
+	Rating int `yaml:"rating,omitempty"`
+}
+
+// SidecarDate is the layout of the sidecar date in local time.
+const SidecarDate = "2006-01-02 15:04:05"
+
+// HiddenExt is the extension of the marker files hiding images,
+// e.g. IMG_1234.hidden or IMG_1234.jpg.hidden.
+const HiddenExt = ".hidden"
+
+// Hides returns whether the source image is hidden with a marker file
+// or listed as hidden in the gallery configuration.
+func (gallery *Gallery) Hides(source string) bool {
+	base := filepath.Base(source)
+	for _, name := range gallery.Config.Hidden {
+		if strings.EqualFold(base, name) || strings.EqualFold(ReplaceExt(base, ""), name) {
+			return true
+		}
+	}
+	for _, marker := range []string{ReplaceExt(source, HiddenExt), source + HiddenExt} {
+		if _, err := Files.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSidecar loads the sidecar of the source image.
+//
+// IMG_1234.yaml may define the title, caption, tags, date and rating,
+// IMG_1234.txt contains only the caption.
+func LoadSidecar(source string) (Sidecar, error) {
+	var sidecar Sidecar
+
+	path := ReplaceExt(source, ".yaml")
+	data, err := readFile(path)
+	if err == nil {
+		if err := yaml.Unmarshal(data, &sidecar); err != nil {
+			return sidecar, fmt.Errorf("%v: %v", path, err)
+		}
+		if sidecar.Date != "" {
+			if _, err := time.ParseInLocation(SidecarDate, sidecar.Date, time.Local); err != nil {
+				return sidecar, fmt.Errorf("%v: invalid date %q", path, sidecar.Date)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return sidecar, err
+	}
+
+	data, err = readFile(ReplaceExt(source, ".txt"))
+	if err == nil {
+		if sidecar.Caption == "" {
+			sidecar.Caption = strings.TrimSpace(string(data))
+		}
+	} else if !os.IsNotExist(err) {
+		return sidecar, err
+	}
+
+	return sidecar, nil
+}
+
+// placeholderDescriptions are image descriptions that cameras write by default.
+var placeholderDescriptions = map[string]bool{
+	"OLYMPUS DIGITAL CAMERA": true,
+	"SONY DSC":               true,
+	"DCIM":                   true,
+	"DIGITAL CAMERA":         true,
+}
+
+// ApplySidecar sets the title and caption of image from the sidecar,
+// falling back to the EXIF image description for the caption.
+func ApplySidecar(image *Image, sidecar Sidecar) {
+	image.Title = image.Name
+	if sidecar.Title != "" {
+		image.Title = sidecar.Title
+	}
+
+	image.Tags = uniqueStrings(append(image.Tags, sidecar.Tags...))
+	image.Rating = sidecar.Rating
+
+	if taken, err := time.ParseInLocation(SidecarDate, sidecar.Date, time.Local); err == nil {
+		if image.Metadata == nil {
+			image.Metadata = &Metadata{}
+		}
+		image.Metadata.Taken = taken
+	}
+
+	image.Caption = sidecar.Caption
+	if image.Caption == "" && image.Metadata != nil {
+		description := strings.TrimSpace(image.Metadata.Description)
+		if !placeholderDescriptions[strings.ToUpper(description)] {
+			image.Caption = description
+		}
+	}
+}
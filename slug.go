@@ -0,0 +1,73 @@
+package gallery
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugs enables converting gallery and image names into URL friendly paths,
+// the names are still displayed as they are.
+var Slugs = true
+
+// SlugSeparator replaces spaces and punctuation in slugs.
+var SlugSeparator = "-"
+
+// transliterations are the letters that don't decompose into ASCII.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ł': "l", 'ı': "i", 'ħ': "h", 'ŋ': "ng",
+}
+
+// Slugify converts name into a lowercase URL path segment,
+// diacritics are removed and letters without an ASCII equivalent are kept.
+// Names without letters or digits are returned as is.
+func Slugify(name string) string {
+	var slug strings.Builder
+	separate := false
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if separate && slug.Len() > 0 {
+				slug.WriteString(SlugSeparator)
+			}
+			separate = false
+			if ascii, ok := transliterations[r]; ok {
+				slug.WriteString(ascii)
+			} else {
+				slug.WriteRune(r)
+			}
+		default:
+			separate = true
+		}
+	}
+	if slug.Len() == 0 {
+		return name
+	}
+	return slug.String()
+}
+
+// SlugPath slugifies the elements of a relative path when Slugs is enabled.
+func SlugPath(unbound string) string {
+	if !Slugs {
+		return unbound
+	}
+	elems := strings.Split(unbound, string(filepath.Separator))
+	for i, elem := range elems {
+		elems[i] = Slugify(elem)
+	}
+	return filepath.Join(elems...)
+}
+
+// SlugFile slugifies a relative file path keeping the extension.
+func SlugFile(unbound string) string {
+	if !Slugs {
+		return unbound
+	}
+	ext := filepath.Ext(unbound)
+	return SlugPath(ReplaceExt(unbound, "")) + strings.ToLower(ext)
+}
@@ -0,0 +1,50 @@
+package gallery
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		want      string
+	}{
+		{"Summer Trip 2020", "-", "summer-trip-2020"},
+		{"Summer Trip 2020", "_", "summer_trip_2020"},
+		{"Café Déjà Vu", "-", "cafe-deja-vu"},
+		{"Straße", "-", "strasse"},
+		{"Ærø", "-", "aero"},
+		{"Łódź", "-", "lodz"},
+		{"  --Hello,, World!--  ", "-", "hello-world"},
+		{"a_b.c", "-", "a-b-c"},
+		{"東京 タワー", "-", "東京-タワー"},
+		{"①", "-", "1"},
+		// names without letters or digits are kept
+		{"!!!", "-", "!!!"},
+	}
+	for _, test := range tests {
+		SlugSeparator = test.separator
+		if got := Slugify(test.name); got != test.want {
+			t.Errorf("Slugify(%q) with %q = %q, expected %q", test.name, test.separator, got, test.want)
+		}
+	}
+	SlugSeparator = "-"
+}
+
+func TestSlugFile(t *testing.T) {
+	tests := []struct {
+		unbound string
+		want    string
+	}{
+		{filepath.Join("Summer Trip", "Beach Day.JPG"), filepath.Join("summer-trip", "beach-day.jpg")},
+		{filepath.Join("2020", "IMG_0001.jpeg"), filepath.Join("2020", "img-0001.jpeg")},
+		{"Ærø.png", "aero.png"},
+	}
+	for _, test := range tests {
+		if got := SlugFile(test.unbound); got != test.want {
+			t.Errorf("SlugFile(%q) = %q, expected %q", test.unbound, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package gallery
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Date returns when the image was taken, falling back to
+// the modification time of the source file.
+func (image *Image) Date() time.Time {
+	if image.Metadata != nil && !image.Metadata.Taken.IsZero() {
+		return image.Metadata.Taken
+	}
+	return image.Info.ModTime()
+}
+
+// imageLess contains comparison functions for the sort orders,
+// manual order keeps the order the images were found in.
+var imageLess = map[string]func(a, b *Image) bool{
+	"date-desc": func(a, b *Image) bool { return a.Date().After(b.Date()) },
+	"date-asc":  func(a, b *Image) bool { return a.Date().Before(b.Date()) },
+	"name-asc":  func(a, b *Image) bool { return a.Name < b.Name },
+	"name-desc": func(a, b *Image) bool { return a.Name > b.Name },
+	"mtime":     func(a, b *Image) bool { return a.Info.ModTime().After(b.Info.ModTime()) },
+	"manual":    func(a, b *Image) bool { return false },
+}
+
+// ValidSortOrder returns an error when order is not known.
+func ValidSortOrder(order string) error {
+	if _, ok := imageLess[order]; !ok {
+		return fmt.Errorf("unknown sort order %q", order)
+	}
+	return nil
+}
+
+// SortOrder returns the sort order used for the gallery,
+// fallback is used when the gallery doesn't configure one.
+func (gallery *Gallery) SortOrder(fallback string) string {
+	if gallery.Config.Sort != "" {
+		return gallery.Config.Sort
+	}
+	return fallback
+}
+
+// SortImages sorts images using the order, ties are ordered by name.
+func SortImages(images []*Image, order string) {
+	less, ok := imageLess[order]
+	if !ok {
+		less = imageLess["date-desc"]
+	}
+	if order == "manual" {
+		return
+	}
+
+	sort.SliceStable(images, func(i, k int) bool {
+		a, b := images[i], images[k]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Raw < b.Raw
+	})
+}
+
+// Newest returns the date of the newest image in the gallery
+// or the nested galleries, falling back to the configured date.
+func (gallery *Gallery) Newest() time.Time {
+	newest := gallery.Date
+	for _, image := range gallery.Images {
+		if date := image.Date(); date.After(newest) {
+			newest = date
+		}
+	}
+	for _, child := range gallery.Children {
+		if date := child.Newest(); date.After(newest) {
+			newest = date
+		}
+	}
+	return newest
+}
+
+// galleryLess contains comparison functions for the gallery orders.
+var galleryLess = map[string]func(a, b *Gallery) bool{
+	"name":   func(a, b *Gallery) bool { return strings.ToLower(a.Path) < strings.ToLower(b.Path) },
+	"newest": func(a, b *Gallery) bool { return a.Newest().After(b.Newest()) },
+	"weight": func(a, b *Gallery) bool { return a.Config.Weight < b.Config.Weight },
+}
+
+// ValidGalleryOrder returns an error when order is not known.
+func ValidGalleryOrder(order string) error {
+	if _, ok := galleryLess[order]; !ok {
+		return fmt.Errorf("unknown gallery order %q", order)
+	}
+	return nil
+}
+
+// SortGalleries sorts galleries using the order, ties are ordered by path.
+func SortGalleries(galleries []*Gallery, order string) {
+	less, ok := galleryLess[order]
+	if !ok {
+		less = galleryLess["name"]
+	}
+
+	sort.SliceStable(galleries, func(i, k int) bool {
+		a, b := galleries[i], galleries[k]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Path < b.Path
+	})
+}
+
+// LoadOrder loads the manual image order from order.txt or order.yaml in dir.
+//
+// order.txt lists a filename per line, empty lines and lines starting
+// with # are ignored. order.yaml contains a list of filenames.
+func LoadOrder(dir string) ([]string, error) {
+	if data, err := readFile(filepath.Join(dir, "order.txt")); err == nil {
+		var names []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			names = append(names, line)
+		}
+		return names, scanner.Err()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "order.yaml")
+	data, err := readFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := yaml.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return names, nil
+}
+
+// ApplyOrder moves the images listed in order to the front,
+// the rest of the images keep their relative order.
+//
+// The names are matched case-insensitively either with
+// the full filename or the filename without extension.
+func ApplyOrder(images []*Image, order []string) {
+	if len(order) == 0 {
+		return
+	}
+
+	rank := map[string]int{}
+	for i, name := range order {
+		name = strings.ToLower(name)
+		if _, exists := rank[name]; !exists {
+			rank[name] = i
+		}
+	}
+
+	position := func(image *Image) int {
+		base := strings.ToLower(filepath.Base(image.Raw))
+		if i, ok := rank[base]; ok {
+			return i
+		}
+		if i, ok := rank[ReplaceExt(base, "")]; ok {
+			return i
+		}
+		return len(order)
+	}
+
+	sort.SliceStable(images, func(i, k int) bool {
+		return position(images[i]) < position(images[k])
+	})
+}
+
+// MatchesName returns whether the source filename matches name,
+// either with or without the extension, ignoring case.
+func (image *Image) MatchesName(name string) bool {
+	base := filepath.Base(image.Raw)
+	return strings.EqualFold(base, name) || strings.EqualFold(ReplaceExt(base, ""), name)
+}
@@ -0,0 +1,47 @@
+package gallery
+
+import (
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// StackImages groups bursts of similar images, images taken within window
+// of the previous one whose hashes differ by at most distance bits.
+// The first image of a burst stays in the gallery grid and the others
+// are added to its Stack. Images without a hash are not stacked.
+func StackImages(images []*Image, hashes map[*Image]uint64, window time.Duration, distance int) {
+	taken := append([]*Image{}, images...)
+	sort.SliceStable(taken, func(i, k int) bool {
+		return taken[i].Date().Before(taken[k].Date())
+	})
+
+	var top, prev *Image
+	for _, image := range taken {
+		hash, ok := hashes[image]
+		if !ok {
+			top, prev = nil, nil
+			continue
+		}
+		if prev != nil && image.Date().Sub(prev.Date()) <= window &&
+			bits.OnesCount64(hash^hashes[prev]) <= distance {
+			top.Stack = append(top.Stack, image)
+			image.StackTop = top
+		} else {
+			top = image
+		}
+		prev = image
+	}
+}
+
+// GridImages returns the images shown in the gallery grid,
+// the images in stacks are reachable from the first image of the stack.
+func (gallery *Gallery) GridImages() []*Image {
+	images := make([]*Image, 0, len(gallery.Images))
+	for _, image := range gallery.Images {
+		if image.StackTop == nil {
+			images = append(images, image)
+		}
+	}
+	return images
+}
@@ -0,0 +1,62 @@
+package gallery
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStackImages(t *testing.T) {
+	type shot struct {
+		name   string
+		second int
+		hash   uint64
+		hashed bool
+	}
+	tests := []struct {
+		name  string
+		shots []shot
+		want  map[string][]string
+	}{
+		{"burst", []shot{{"a", 0, 0x0, true}, {"b", 1, 0x1, true}, {"c", 2, 0x3, true}},
+			map[string][]string{"a": {"b", "c"}}},
+		{"apart", []shot{{"a", 0, 0x0, true}, {"b", 5, 0x0, true}},
+			map[string][]string{}},
+		{"window from previous", []shot{{"a", 0, 0x0, true}, {"b", 2, 0x0, true}, {"c", 4, 0x0, true}},
+			map[string][]string{"a": {"b", "c"}}},
+		{"different", []shot{{"a", 0, 0x00, true}, {"b", 1, 0xFF, true}, {"c", 2, 0xFE, true}},
+			map[string][]string{"b": {"c"}}},
+		{"unhashed", []shot{{"a", 0, 0x0, true}, {"b", 1, 0x0, false}, {"c", 2, 0x0, true}},
+			map[string][]string{}},
+		{"unsorted", []shot{{"c", 2, 0x0, true}, {"a", 0, 0x0, true}, {"b", 1, 0x0, true}},
+			map[string][]string{"a": {"b", "c"}}},
+	}
+
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		var images []*Image
+		hashes := map[*Image]uint64{}
+		for _, shot := range test.shots {
+			image := &Image{Name: shot.name, Metadata: &Metadata{Taken: start.Add(time.Duration(shot.second) * time.Second)}}
+			images = append(images, image)
+			if shot.hashed {
+				hashes[image] = shot.hash
+			}
+		}
+
+		StackImages(images, hashes, 2*time.Second, 4)
+
+		stacks := map[string][]string{}
+		for _, image := range images {
+			for _, stacked := range image.Stack {
+				stacks[image.Name] = append(stacks[image.Name], stacked.Name)
+				if stacked.StackTop != image {
+					t.Errorf("%v: %v isn't on top of %v", test.name, image.Name, stacked.Name)
+				}
+			}
+		}
+		if !reflect.DeepEqual(stacks, test.want) {
+			t.Errorf("%v: stacked %v, expected %v", test.name, stacks, test.want)
+		}
+	}
+}
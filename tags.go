@@ -0,0 +1,105 @@
+package gallery
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tag is a keyword shared by images across galleries.
+type Tag struct {
+	Name   string
+	Slug   string
+	Images []*Image
+}
+
+func (tag *Tag) PageLink() string {
+	return path.Join("/", "tags", tag.Slug) + "/"
+}
+
+// TagSlug converts a tag name into a URL path segment.
+func TagSlug(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			slug.WriteRune(r)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(slug.String(), "-")
+}
+
+// TagLinks returns the tags of the image for linking to tag pages.
+func (image *Image) TagLinks() []*Tag {
+	var tags []*Tag
+	for _, name := range image.Tags {
+		if slug := TagSlug(name); slug != "" {
+			tags = append(tags, &Tag{Name: name, Slug: slug})
+		}
+	}
+	return tags
+}
+
+// CollectTags groups the images of all galleries by tag,
+// the tags are sorted by name and their images using order.
+func CollectTags(galleries map[string]*Gallery, order string) []*Tag {
+	bySlug := map[string]*Tag{}
+	for _, gallery := range galleries {
+		for _, image := range gallery.Images {
+			for _, name := range image.Tags {
+				slug := TagSlug(name)
+				if slug == "" {
+					continue
+				}
+				tag, ok := bySlug[slug]
+				if !ok {
+					tag = &Tag{Name: name, Slug: slug}
+					bySlug[slug] = tag
+				}
+				tag.Images = append(tag.Images, image)
+			}
+		}
+	}
+
+	var tags []*Tag
+	for _, tag := range bySlug {
+		SortImages(tag.Images, order)
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, k int) bool {
+		return tags[i].Slug < tags[k].Slug
+	})
+	return tags
+}
+
+// TagCloudEntry is a tag with a relative weight for the tag cloud.
+type TagCloudEntry struct {
+	*Tag
+	// Weight is between 1 and 5 based on the image count.
+	Weight int
+}
+
+// TagCloud assigns weights to tags based on how many images they have.
+func TagCloud(tags []*Tag) []TagCloudEntry {
+	max := 1
+	for _, tag := range tags {
+		if len(tag.Images) > max {
+			max = len(tag.Images)
+		}
+	}
+
+	var cloud []TagCloudEntry
+	for _, tag := range tags {
+		weight := 1
+		if max > 1 {
+			weight = 1 + 4*(len(tag.Images)-1)/(max-1)
+		}
+		cloud = append(cloud, TagCloudEntry{Tag: tag, Weight: weight})
+	}
+	return cloud
+}
@@ -0,0 +1,47 @@
+package gallery
+
+import "testing"
+
+func TestTagCloud(t *testing.T) {
+	tagged := func(name string, n int) *Tag {
+		return &Tag{Name: name, Images: make([]*Image, n)}
+	}
+
+	tests := []struct {
+		name   string
+		counts []int
+		want   []int
+	}{
+		{"single", []int{1}, []int{1}},
+		{"equal", []int{1, 1}, []int{1, 1}},
+		{"two", []int{1, 2}, []int{1, 5}},
+		{"range", []int{1, 3, 5, 9}, []int{1, 2, 3, 5}},
+		{"large", []int{100, 1, 50}, []int{5, 1, 2}},
+	}
+	for _, test := range tests {
+		var tags []*Tag
+		for _, n := range test.counts {
+			tags = append(tags, tagged("tag", n))
+		}
+		cloud := TagCloud(tags)
+		for i, entry := range cloud {
+			if entry.Weight != test.want[i] {
+				t.Errorf("%v: weight of %d images is %d, expected %d", test.name, test.counts[i], entry.Weight, test.want[i])
+			}
+		}
+	}
+}
+
+func TestTagSlug(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"Estonia", "estonia"},
+		{" Black & White ", "black-white"},
+		{"Tallinn, Old Town!", "tallinn-old-town"},
+		{"Õismäe", "õismäe"},
+	}
+	for _, test := range tests {
+		if got := TagSlug(test.name); got != test.want {
+			t.Errorf("TagSlug(%q) = %q, expected %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gallery
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// UnlistedSecretFile contains the secret the unlisted gallery tokens are
+// derived from, it's created when missing. Keep it to retain the links.
+var UnlistedSecretFile = ".unlisted-secret"
+
+var unlistedSecret struct {
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// loadUnlistedSecret reads or creates UnlistedSecretFile.
+func loadUnlistedSecret() ([]byte, error) {
+	unlistedSecret.once.Do(func() {
+		key, err := ioutil.ReadFile(UnlistedSecretFile)
+		if os.IsNotExist(err) {
+			key = make([]byte, 32)
+			if _, err = rand.Read(key); err == nil {
+				err = ioutil.WriteFile(UnlistedSecretFile, key, 0600)
+			}
+		}
+		if err == nil && len(key) == 0 {
+			err = fmt.Errorf("%v is empty", UnlistedSecretFile)
+		}
+		unlistedSecret.key, unlistedSecret.err = key, err
+	})
+	return unlistedSecret.key, unlistedSecret.err
+}
+
+// validToken matches the tokens that can be configured for unlisted galleries.
+var validToken = regexp.MustCompile(`^[a-zA-Z0-9_-]{16,}$`)
+
+// Unlisted returns whether the gallery, or a gallery containing it,
+// is only reachable by its link.
+func (gallery *Gallery) Unlisted() bool {
+	for g := gallery; g != nil; g = g.Parent {
+		if g.Config.Visibility == VisibilityUnlisted {
+			return true
+		}
+	}
+	return false
+}
+
+// Listed returns the published galleries that are not unlisted.
+func Listed(galleries map[string]*Gallery) map[string]*Gallery {
+	listed := map[string]*Gallery{}
+	for key, gallery := range galleries {
+		if !gallery.Unlisted() {
+			listed[key] = gallery
+		}
+	}
+	return listed
+}
+
+// UnlistedToken returns the token in the path of an unlisted gallery,
+// either configured with token or derived from the gallery directory.
+func (gallery *Gallery) UnlistedToken(imagesDir string) (string, error) {
+	if gallery.Config.Token != "" {
+		return gallery.Config.Token, nil
+	}
+	key, err := loadUnlistedSecret()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(imagesDir, gallery.Path)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(filepath.ToSlash(rel))))
+	sum := mac.Sum(nil)
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(sum[:16])), nil
+}
+
+// publishUnlisted moves the unlisted galleries, together with their images
+// and nested galleries, under their token, e.g. "3bx.../summer".
+func publishUnlisted(imagesDir string, galleries map[string]*Gallery) error {
+	for _, gallery := range galleries {
+		if gallery.Config.Visibility != VisibilityUnlisted || (gallery.Parent != nil && gallery.Parent.Unlisted()) {
+			continue
+		}
+		token, err := gallery.UnlistedToken(imagesDir)
+		if err != nil {
+			return fmt.Errorf("%v: %v", gallery.Path, err)
+		}
+
+		prefix := gallery.Unbound + string(filepath.Separator)
+		unbound := filepath.Join(token, filepath.Base(gallery.Unbound))
+		rebase := func(path string) string {
+			return filepath.Join(unbound, strings.TrimPrefix(path, prefix))
+		}
+
+		pending := []*Gallery{gallery}
+		for len(pending) > 0 {
+			g := pending[len(pending)-1]
+			pending = append(pending[:len(pending)-1], g.Children...)
+			for _, image := range g.Images {
+				image.Unbound = rebase(image.Unbound)
+				image.Path = filepath.Join(ImagesDir, image.Unbound)
+			}
+			if g != gallery {
+				g.Unbound = rebase(g.Unbound)
+			}
+		}
+		gallery.Unbound = unbound
+	}
+	return nil
+}
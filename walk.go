@@ -0,0 +1,83 @@
+package gallery
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FollowSymlinks enables scanning symlinked directories,
+// symlinked files are always scanned.
+var FollowSymlinks = false
+
+// walk calls fn for the files and directories in root like filepath.Walk.
+//
+// With FollowSymlinks the symlinks are followed, the paths keep the name
+// of the link. Each directory is walked once, links to a directory that
+// has already been walked, e.g. one containing the link or one reached
+// through another link, are skipped. Broken links are ignored.
+func walk(root string, fn filepath.WalkFunc) error {
+	if !FollowSymlinks {
+		return filepath.Walk(root, fn)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFollow(root, info, map[string]bool{}, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkFollow walks path following the symlinks, visited contains
+// the resolved directories that have been walked.
+func walkFollow(path string, info os.FileInfo, visited map[string]bool, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	if visited[real] {
+		return nil
+	}
+
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+	visited[real] = true
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		info, err := os.Stat(child)
+		if err != nil {
+			if link, lerr := os.Lstat(child); lerr == nil && link.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+			if err := fn(child, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if err := walkFollow(child, info, visited, fn); err != nil {
+			if err == filepath.SkipDir && info.IsDir() {
+				continue
+			}
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
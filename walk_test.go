@@ -0,0 +1,65 @@
+package gallery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkFollow(t *testing.T) {
+	tests := []struct {
+		name  string
+		links map[string]string
+		want  []string
+	}{
+		{"no links", nil, []string{"a/x.jpg"}},
+		{"sibling links", map[string]string{"b": "a", "c": "a"}, []string{"a/x.jpg"}},
+		{"nested link", map[string]string{"d/e": "../a"}, []string{"a/x.jpg"}},
+		{"cycle", map[string]string{"a/up": ".."}, []string{"a/x.jpg"}},
+		{"outside", map[string]string{"o": "../outside"}, []string{"a/x.jpg", "o/y.jpg"}},
+		{"two links outside", map[string]string{"o": "../outside", "p": "../outside"}, []string{"a/x.jpg", "o/y.jpg"}},
+		{"broken", map[string]string{"b": "missing"}, []string{"a/x.jpg"}},
+	}
+
+	defer func(follow bool) { FollowSymlinks = follow }(FollowSymlinks)
+	FollowSymlinks = true
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "images")
+		for _, file := range []string{filepath.Join(root, "a", "x.jpg"), filepath.Join(dir, "outside", "y.jpg")} {
+			os.MkdirAll(filepath.Dir(file), 0755)
+			if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for link, target := range test.links {
+			os.MkdirAll(filepath.Dir(filepath.Join(root, link)), 0755)
+			if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+				t.Skip(err)
+			}
+		}
+
+		var files []string
+		err := walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				rel, _ := filepath.Rel(root, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, test.want) {
+			t.Errorf("%v: walked %v, expected %v", test.name, files, test.want)
+		}
+	}
+}